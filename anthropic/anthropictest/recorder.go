@@ -0,0 +1,261 @@
+// Package anthropictest provides utilities for testing code that uses the
+// anthropic package without calling the live API.
+package anthropictest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+// DefaultCassetteDir is the directory cassettes are stored in unless WithCassetteDir is used.
+const DefaultCassetteDir = "testdata/cassettes"
+
+// Mode controls how a Recorder handles requests.
+type Mode int
+
+const (
+	// ModeReplay serves requests from the test's cassette. Requests without a
+	// matching recorded interaction are sent to the real transport and recorded.
+	ModeReplay Mode = iota
+	// ModeStrictReplay serves requests from the test's cassette only. A request
+	// without a matching recorded interaction fails the test.
+	ModeStrictReplay
+	// ModeRecord sends every request to the real transport and overwrites the
+	// test's cassette with the recorded interactions.
+	ModeRecord
+)
+
+// Interaction is a recorded request and its response.
+// Request headers are not recorded, so cassettes never contain API keys.
+type Interaction struct {
+	Method         string      `json:"method"`
+	Path           string      `json:"path"`
+	RequestBody    string      `json:"request_body,omitempty"`
+	StatusCode     int         `json:"status_code"`
+	ResponseHeader http.Header `json:"response_header,omitempty"`
+	ResponseBody   string      `json:"response_body"`
+}
+
+// Recorder is an http.RoundTripper that records and replays API interactions in a
+// cassette file named after the running test.
+type Recorder struct {
+	tb        testing.TB
+	mode      Mode
+	dir       string
+	transport http.RoundTripper
+
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+	recorded     []Interaction
+}
+
+// Option configures a Recorder.
+type Option func(*Recorder)
+
+// WithMode sets the recorder's mode. The default is ModeReplay.
+func WithMode(mode Mode) Option {
+	return func(r *Recorder) {
+		r.mode = mode
+	}
+}
+
+// WithCassetteDir sets the directory cassettes are read from and written to.
+func WithCassetteDir(dir string) Option {
+	return func(r *Recorder) {
+		r.dir = dir
+	}
+}
+
+// WithTransport sets the transport used to send requests that are not replayed.
+// The default is http.DefaultTransport.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(r *Recorder) {
+		r.transport = transport
+	}
+}
+
+// NewRecorder creates a Recorder whose cassette is named after tb.Name().
+// Newly recorded interactions are saved when the test completes.
+func NewRecorder(tb testing.TB, opts ...Option) *Recorder {
+	tb.Helper()
+	r := &Recorder{
+		tb:        tb,
+		mode:      ModeReplay,
+		dir:       DefaultCassetteDir,
+		transport: http.DefaultTransport,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.mode != ModeRecord {
+		interactions, err := loadCassette(r.Path())
+		if err != nil && !os.IsNotExist(err) {
+			tb.Fatalf("anthropictest: loading cassette: %v", err)
+		}
+		r.interactions = interactions
+		r.used = make([]bool, len(interactions))
+	}
+
+	tb.Cleanup(func() {
+		if err := r.save(); err != nil {
+			tb.Errorf("anthropictest: saving cassette: %v", err)
+		}
+	})
+	return r
+}
+
+// HTTPClient returns an *http.Client that sends requests through the recorder,
+// for use with anthropic.WithHTTPClient.
+func (r *Recorder) HTTPClient() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// Path returns the path of the test's cassette file.
+func (r *Recorder) Path() string {
+	return filepath.Join(r.dir, cassetteName(r.tb.Name())+".json")
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("anthropictest: reading request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if r.mode != ModeRecord {
+		if interaction, ok := r.match(req, body); ok {
+			return interaction.response(req), nil
+		}
+		if r.mode == ModeStrictReplay {
+			r.tb.Errorf("anthropictest: no recorded interaction in %s for %s %s", r.Path(), req.Method, req.URL.Path)
+			return nil, fmt.Errorf("anthropictest: no recorded interaction for %s %s", req.Method, req.URL.Path)
+		}
+	}
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("anthropictest: reading response body: %w", err)
+	}
+
+	interaction := Interaction{
+		Method:         req.Method,
+		Path:           req.URL.Path,
+		RequestBody:    string(body),
+		StatusCode:     resp.StatusCode,
+		ResponseHeader: resp.Header.Clone(),
+		ResponseBody:   string(respBody),
+	}
+	r.mu.Lock()
+	r.recorded = append(r.recorded, interaction)
+	r.mu.Unlock()
+	return interaction.response(req), nil
+}
+
+// match returns the first unused interaction matching the request's method, path
+// and body, marking it as used so that repeated identical requests replay in order.
+func (r *Recorder) match(req *http.Request, body []byte) (Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.interactions {
+		if r.used[i] || interaction.Method != req.Method || interaction.Path != req.URL.Path {
+			continue
+		}
+		if !jsonEqual(interaction.RequestBody, string(body)) {
+			continue
+		}
+		r.used[i] = true
+		return interaction, true
+	}
+	return Interaction{}, false
+}
+
+// save writes the cassette if any interactions were recorded.
+func (r *Recorder) save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.recorded) == 0 {
+		return nil
+	}
+	interactions := r.recorded
+	if r.mode != ModeRecord {
+		interactions = append(append([]Interaction(nil), r.interactions...), r.recorded...)
+	}
+	data, err := json.MarshalIndent(interactions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.Path(), data, 0o644)
+}
+
+func (i Interaction) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", i.StatusCode, http.StatusText(i.StatusCode)),
+		StatusCode:    i.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        i.ResponseHeader.Clone(),
+		Body:          io.NopCloser(bytes.NewReader([]byte(i.ResponseBody))),
+		ContentLength: int64(len(i.ResponseBody)),
+		Request:       req,
+	}
+}
+
+func loadCassette(path string) ([]Interaction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var interactions []Interaction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return interactions, nil
+}
+
+var unsafeNameChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// cassetteName turns a test name, which may contain subtest separators and spaces,
+// into a file name.
+func cassetteName(testName string) string {
+	return unsafeNameChars.ReplaceAllString(testName, "_")
+}
+
+// jsonEqual reports whether a and b are equal JSON documents, falling back to a
+// string comparison if either is not valid JSON.
+func jsonEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	ja, _ := json.Marshal(va)
+	jb, _ := json.Marshal(vb)
+	return bytes.Equal(ja, jb)
+}
@@ -0,0 +1,165 @@
+package anthropictest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/XiaoConstantine/anthropic-go/anthropic"
+)
+
+// fakeTB stands in for the test a Recorder is created for, so that a cassette can be
+// recorded and replayed under the same name and strict replay failures observed.
+type fakeTB struct {
+	testing.TB
+	name     string
+	cleanups []func()
+	errors   []string
+}
+
+func (f *fakeTB) Name() string      { return f.name }
+func (f *fakeTB) Helper()           {}
+func (f *fakeTB) Cleanup(fn func()) { f.cleanups = append(f.cleanups, fn) }
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+func (f *fakeTB) Fatalf(format string, args ...interface{}) {
+	f.TB.Fatalf(format, args...)
+}
+
+func (f *fakeTB) finish() {
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
+
+func newCountingServer(t *testing.T, hits *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*hits++
+		var params anthropic.MessageParams
+		_ = json.NewDecoder(r.Body).Decode(&params)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(anthropic.Message{
+			ID:      fmt.Sprintf("msg_%d", *hits),
+			Role:    "assistant",
+			Content: []anthropic.ContentBlock{{Type: "text", Text: "echo: " + params.Messages[0].Content[0].Text}},
+		})
+	}))
+}
+
+func ask(t *testing.T, recorder *Recorder, baseURL, prompt string) (*anthropic.Message, error) {
+	client, err := anthropic.NewClient(
+		anthropic.WithAPIKey("secret-key"),
+		anthropic.WithBaseURL(baseURL),
+		anthropic.WithHTTPClient(recorder.HTTPClient()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return client.Messages().Create(context.Background(), &anthropic.MessageParams{
+		Model:    string(anthropic.ModelHaiku),
+		Messages: []anthropic.MessageParam{{Role: "user", Content: []anthropic.ContentBlock{{Type: "text", Text: prompt}}}},
+	})
+}
+
+func TestRecorderRecordAndReplay(t *testing.T) {
+	var hits int
+	server := newCountingServer(t, &hits)
+	defer server.Close()
+	dir := t.TempDir()
+
+	recording := &fakeTB{TB: t, name: "TestAgent/answers questions"}
+	recorder := NewRecorder(recording, WithMode(ModeRecord), WithCassetteDir(dir))
+	message, err := ask(t, recorder, server.URL, "hello")
+	if err != nil {
+		t.Fatalf("Failed to record: %v", err)
+	}
+	recording.finish()
+
+	if want := filepath.Join(dir, "TestAgent_answers_questions.json"); recorder.Path() != want {
+		t.Errorf("Expected cassette path %s, got %s", want, recorder.Path())
+	}
+	data, err := os.ReadFile(recorder.Path())
+	if err != nil {
+		t.Fatalf("Expected the cassette to be written: %v", err)
+	}
+	if strings.Contains(string(data), "secret-key") {
+		t.Errorf("Expected the cassette not to contain the API key")
+	}
+
+	replaying := &fakeTB{TB: t, name: "TestAgent/answers questions"}
+	replayer := NewRecorder(replaying, WithMode(ModeStrictReplay), WithCassetteDir(dir))
+	replayed, err := ask(t, replayer, server.URL, "hello")
+	if err != nil {
+		t.Fatalf("Failed to replay: %v", err)
+	}
+	replaying.finish()
+
+	if hits != 1 {
+		t.Errorf("Expected the server to be hit once, got %d", hits)
+	}
+	if replayed.ID != message.ID || replayed.Text() != "echo: hello" {
+		t.Errorf("Expected the replayed message to match the recording, got %+v", replayed)
+	}
+	if len(replaying.errors) != 0 {
+		t.Errorf("Unexpected errors: %v", replaying.errors)
+	}
+}
+
+func TestRecorderStrictReplayMissingFixture(t *testing.T) {
+	dir := t.TempDir()
+	tb := &fakeTB{TB: t, name: "TestMissing"}
+	recorder := NewRecorder(tb, WithMode(ModeStrictReplay), WithCassetteDir(dir))
+
+	if _, err := ask(t, recorder, "https://api.anthropic.com/v1", "hello"); err == nil {
+		t.Fatal("Expected an error for a request without a fixture")
+	}
+	tb.finish()
+
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "no recorded interaction") {
+		t.Errorf("Expected the test to be failed for the missing fixture, got %v", tb.errors)
+	}
+	if _, err := os.Stat(recorder.Path()); !os.IsNotExist(err) {
+		t.Errorf("Expected no cassette to be written, got %v", err)
+	}
+}
+
+func TestRecorderReplayRecordsMissing(t *testing.T) {
+	var hits int
+	server := newCountingServer(t, &hits)
+	defer server.Close()
+	dir := t.TempDir()
+
+	first := &fakeTB{TB: t, name: "TestIncremental"}
+	if _, err := ask(t, NewRecorder(first, WithCassetteDir(dir)), server.URL, "one"); err != nil {
+		t.Fatalf("Failed to record: %v", err)
+	}
+	first.finish()
+
+	second := &fakeTB{TB: t, name: "TestIncremental"}
+	recorder := NewRecorder(second, WithCassetteDir(dir))
+	for _, prompt := range []string{"one", "two"} {
+		message, err := ask(t, recorder, server.URL, prompt)
+		if err != nil {
+			t.Fatalf("Failed to send %q: %v", prompt, err)
+		}
+		if message.Text() != "echo: "+prompt {
+			t.Errorf("Expected echo of %q, got %q", prompt, message.Text())
+		}
+	}
+	second.finish()
+
+	if hits != 2 {
+		t.Errorf("Expected only the unrecorded request to reach the server, got %d hits", hits)
+	}
+	interactions, err := loadCassette(recorder.Path())
+	if err != nil || len(interactions) != 2 {
+		t.Errorf("Expected both interactions in the cassette, got %d (%v)", len(interactions), err)
+	}
+}
@@ -0,0 +1,295 @@
+package anthropictest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/XiaoConstantine/anthropic-go/anthropic"
+)
+
+// DefaultToolUseID is the id of the tool call served by a ToolRoundTrip.
+const DefaultToolUseID = "toolu_anthropictest"
+
+// ToolRoundTrip is an http.RoundTripper that scripts a tool-use round trip for testing
+// tool dispatch: the first messages request is answered with a call to the tool, and
+// every later one with a final text answer. Streaming requests get the tool call as a
+// realistic event stream, with its input split across several input_json_delta events.
+type ToolRoundTrip struct {
+	tb           testing.TB
+	toolName     string
+	input        json.RawMessage
+	fragmentSize int
+	finalText    string
+
+	mu       sync.Mutex
+	requests []anthropic.MessageParams
+	bodies   [][]byte
+}
+
+// ToolRoundTripOption configures a ToolRoundTrip.
+type ToolRoundTripOption func(*ToolRoundTrip)
+
+// WithFragmentSize sets the number of characters of the tool input sent in each
+// input_json_delta event. The default is 8.
+func WithFragmentSize(size int) ToolRoundTripOption {
+	return func(rt *ToolRoundTrip) {
+		rt.fragmentSize = size
+	}
+}
+
+// WithFinalText sets the text of the answer to the requests following the tool call.
+func WithFinalText(text string) ToolRoundTripOption {
+	return func(rt *ToolRoundTrip) {
+		rt.finalText = text
+	}
+}
+
+// NewToolRoundTrip creates a ToolRoundTrip that calls the named tool with input,
+// marshaled as JSON.
+func NewToolRoundTrip(tb testing.TB, toolName string, input interface{}, opts ...ToolRoundTripOption) *ToolRoundTrip {
+	tb.Helper()
+	data, err := json.Marshal(input)
+	if err != nil {
+		tb.Fatalf("anthropictest: marshaling tool input: %v", err)
+	}
+	rt := &ToolRoundTrip{
+		tb:           tb,
+		toolName:     toolName,
+		input:        data,
+		fragmentSize: 8,
+		finalText:    "Done.",
+	}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	return rt
+}
+
+// HTTPClient returns an *http.Client that sends requests through the round trip,
+// for use with anthropic.WithHTTPClient.
+func (rt *ToolRoundTrip) HTTPClient() *http.Client {
+	return &http.Client{Transport: rt}
+}
+
+// Requests returns the messages requests received so far.
+func (rt *ToolRoundTrip) Requests() []anthropic.MessageParams {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return append([]anthropic.MessageParams(nil), rt.requests...)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (rt *ToolRoundTrip) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(req.URL.Path, "/messages") {
+		rt.tb.Errorf("anthropictest: unexpected request %s %s", req.Method, req.URL.Path)
+		return nil, fmt.Errorf("anthropictest: unexpected request %s %s", req.Method, req.URL.Path)
+	}
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("anthropictest: reading request body: %w", err)
+		}
+	}
+	var fields messagesRequest
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("anthropictest: parsing request body: %w", err)
+	}
+	params := anthropic.MessageParams{Model: fields.Model, MaxTokens: fields.MaxTokens, Messages: fields.Messages}
+	// A system prompt sent as content blocks, e.g. with a cache breakpoint, has no
+	// string form and is left out.
+	_ = json.Unmarshal(fields.System, &params.System)
+
+	rt.mu.Lock()
+	rt.requests = append(rt.requests, params)
+	rt.bodies = append(rt.bodies, body)
+	first := len(rt.requests) == 1
+	rt.mu.Unlock()
+
+	message := anthropic.Message{
+		ID:         "msg_anthropictest_final",
+		Type:       "message",
+		Role:       "assistant",
+		Model:      params.Model,
+		Content:    []anthropic.ContentBlock{anthropic.TextContent(rt.finalText)},
+		StopReason: "end_turn",
+	}
+	if first {
+		message.ID = "msg_anthropictest_tool_use"
+		message.Content = []anthropic.ContentBlock{anthropic.ToolUseContent(DefaultToolUseID, rt.toolName, rt.input)}
+		message.StopReason = "tool_use"
+	}
+
+	header := http.Header{}
+	var respBody string
+	switch {
+	case fields.Stream && first:
+		header.Set("Content-Type", "text/event-stream")
+		respBody = ToolUseStream(params.Model, DefaultToolUseID, rt.toolName, rt.input, rt.fragmentSize)
+	case fields.Stream:
+		header.Set("Content-Type", "text/event-stream")
+		respBody = textStream(params.Model, message.ID, rt.finalText)
+	default:
+		data, err := json.Marshal(message)
+		if err != nil {
+			return nil, err
+		}
+		header.Set("Content-Type", "application/json")
+		respBody = string(data)
+	}
+	return Interaction{StatusCode: http.StatusOK, ResponseHeader: header, ResponseBody: respBody}.response(req), nil
+}
+
+// messagesRequest holds the fields of a messages request that the round trip reads.
+// The system prompt is kept raw, since clients send it either as a string or as an
+// array of content blocks.
+type messagesRequest struct {
+	Model     string                   `json:"model"`
+	MaxTokens int                      `json:"max_tokens"`
+	Messages  []anthropic.MessageParam `json:"messages"`
+	System    json.RawMessage          `json:"system"`
+	Stream    bool                     `json:"stream"`
+}
+
+// AssertToolResult fails the test unless the request following the tool call answers
+// it with a tool_result block whose output is expected. The block is checked in the
+// API's wire format, with tool_use_id and content at the top level of the block.
+func (rt *ToolRoundTrip) AssertToolResult(expected string) {
+	rt.tb.Helper()
+	rt.mu.Lock()
+	bodies := append([][]byte(nil), rt.bodies...)
+	rt.mu.Unlock()
+	if len(bodies) < 2 {
+		rt.tb.Errorf("anthropictest: expected a request following the tool call, got %d requests", len(bodies))
+		return
+	}
+
+	var followUp struct {
+		Messages []struct {
+			Content []struct {
+				Type      string          `json:"type"`
+				ToolUseID string          `json:"tool_use_id"`
+				Content   json.RawMessage `json:"content"`
+			} `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(bodies[1], &followUp); err != nil || len(followUp.Messages) == 0 {
+		rt.tb.Errorf("anthropictest: expected the follow-up request to have messages, got %s", bodies[1])
+		return
+	}
+	last := followUp.Messages[len(followUp.Messages)-1]
+	for _, block := range last.Content {
+		if block.Type != "tool_result" || block.ToolUseID != DefaultToolUseID {
+			continue
+		}
+		if output := toolResultText(block.Content); output != expected {
+			rt.tb.Errorf("anthropictest: expected tool_result output %q, got %q", expected, output)
+		}
+		return
+	}
+	rt.tb.Errorf("anthropictest: expected the follow-up request's last message to hold a tool_result with tool_use_id %s, got %s", DefaultToolUseID, bodies[1])
+}
+
+// toolResultText returns the text of a tool_result block's content, which is either a
+// string or an array of content blocks whose text blocks are concatenated.
+func toolResultText(content json.RawMessage) string {
+	var text string
+	if json.Unmarshal(content, &text) == nil {
+		return text
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	_ = json.Unmarshal(content, &blocks)
+	var sb strings.Builder
+	for _, block := range blocks {
+		if block.Type == "text" {
+			sb.WriteString(block.Text)
+		}
+	}
+	return sb.String()
+}
+
+// ToolUseStream returns the body of a streaming response in which the model calls the
+// named tool with input, sent in input_json_delta fragments of fragmentSize characters.
+func ToolUseStream(model, toolUseID, toolName string, input json.RawMessage, fragmentSize int) string {
+	events := []interface{}{
+		map[string]interface{}{
+			"type":    "message_start",
+			"message": map[string]interface{}{"id": "msg_anthropictest_tool_use", "type": "message", "role": "assistant", "model": model, "content": []interface{}{}, "usage": map[string]int{"input_tokens": 10}},
+		},
+		map[string]interface{}{
+			"type":          "content_block_start",
+			"index":         0,
+			"content_block": map[string]interface{}{"type": "tool_use", "id": toolUseID, "name": toolName, "input": map[string]interface{}{}},
+		},
+	}
+	for _, fragment := range splitRunes(string(input), fragmentSize) {
+		events = append(events, map[string]interface{}{
+			"type":  "content_block_delta",
+			"index": 0,
+			"delta": map[string]interface{}{"type": "input_json_delta", "partial_json": fragment},
+		})
+	}
+	events = append(events,
+		map[string]interface{}{"type": "content_block_stop", "index": 0},
+		map[string]interface{}{"type": "message_delta", "delta": map[string]interface{}{"stop_reason": "tool_use"}, "usage": map[string]int{"output_tokens": 10}},
+		map[string]interface{}{"type": "message_stop"},
+	)
+	return encodeEvents(events)
+}
+
+// textStream returns the body of a streaming response holding a single text block.
+func textStream(model, id, text string) string {
+	return encodeEvents([]interface{}{
+		map[string]interface{}{
+			"type":    "message_start",
+			"message": map[string]interface{}{"id": id, "type": "message", "role": "assistant", "model": model, "content": []interface{}{}, "usage": map[string]int{"input_tokens": 10}},
+		},
+		map[string]interface{}{"type": "content_block_start", "index": 0, "content_block": map[string]interface{}{"type": "text", "text": ""}},
+		map[string]interface{}{"type": "content_block_delta", "index": 0, "delta": map[string]interface{}{"type": "text_delta", "text": text}},
+		map[string]interface{}{"type": "content_block_stop", "index": 0},
+		map[string]interface{}{"type": "message_delta", "delta": map[string]interface{}{"stop_reason": "end_turn"}, "usage": map[string]int{"output_tokens": 10}},
+		map[string]interface{}{"type": "message_stop"},
+	})
+}
+
+// encodeEvents encodes events as server-sent events named after their type.
+func encodeEvents(events []interface{}) string {
+	var buf bytes.Buffer
+	for _, event := range events {
+		data, _ := json.Marshal(event)
+		fmt.Fprintf(&buf, "event: %s\ndata: %s\n\n", event.(map[string]interface{})["type"], data)
+	}
+	return buf.String()
+}
+
+// splitRunes splits s into pieces of at most size characters, never splitting a
+// multi-byte character.
+func splitRunes(s string, size int) []string {
+	if size <= 0 {
+		size = 1
+	}
+	var pieces []string
+	for len(s) > 0 {
+		end, n := 0, 0
+		for end < len(s) && n < size {
+			_, width := utf8.DecodeRuneInString(s[end:])
+			end += width
+			n++
+		}
+		pieces = append(pieces, s[:end])
+		s = s[end:]
+	}
+	return pieces
+}
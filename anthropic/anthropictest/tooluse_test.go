@@ -0,0 +1,173 @@
+package anthropictest
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/XiaoConstantine/anthropic-go/anthropic"
+)
+
+func TestToolRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name      string
+		streaming bool
+	}{
+		{name: "Streaming", streaming: true},
+		{name: "Non-streaming"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			input := map[string]string{"city": "Zürich", "unit": "celsius"}
+			rt := NewToolRoundTrip(t, "get_weather", input, WithFinalText("It is sunny."))
+			client, err := anthropic.NewClient(anthropic.WithAPIKey("test-key"), anthropic.WithHTTPClient(rt.HTTPClient()))
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+
+			var fragments []string
+			params := &anthropic.MessageParams{
+				Model:     string(anthropic.ModelHaiku),
+				MaxTokens: 1024,
+				Messages:  []anthropic.MessageParam{{Role: "user", Content: []anthropic.ContentBlock{anthropic.TextContent("Weather in Zürich?")}}},
+			}
+			if tc.streaming {
+				params.ToolInputDeltaFunc = func(ctx context.Context, toolUseID string, fragment []byte) error {
+					fragments = append(fragments, string(fragment))
+					return nil
+				}
+			}
+			message, err := client.Messages().Create(context.Background(), params)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var calls []*anthropic.ToolCall
+			for _, block := range message.Content {
+				if block.ToolCall != nil {
+					calls = append(calls, block.ToolCall)
+				}
+			}
+			if len(calls) != 1 || calls[0].Name != "get_weather" || calls[0].ID != DefaultToolUseID {
+				t.Fatalf("Expected a call to get_weather, got %+v", calls)
+			}
+			var got map[string]string
+			if err := json.Unmarshal(calls[0].Input, &got); err != nil || got["city"] != "Zürich" || got["unit"] != "celsius" {
+				t.Errorf("Expected input %v, got %s (%v)", input, calls[0].Input, err)
+			}
+			if tc.streaming && len(fragments) < 2 {
+				t.Errorf("Expected the input in several fragments, got %q", fragments)
+			}
+
+			params.Messages = append(params.Messages,
+				message.ToParam(),
+				anthropic.MessageParam{Role: "user", Content: []anthropic.ContentBlock{anthropic.ToolResultContent(calls[0].ID, "22 degrees", false)}},
+			)
+			final, err := client.Messages().Create(context.Background(), params)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if final.Text() != "It is sunny." || final.StopReason != "end_turn" {
+				t.Errorf("Expected the final answer, got %+v", final)
+			}
+			rt.AssertToolResult("22 degrees")
+		})
+	}
+}
+
+func TestToolRoundTripAssertToolResultFailures(t *testing.T) {
+	tb := &fakeTB{TB: t, name: "TestToolRoundTrip"}
+	rt := NewToolRoundTrip(tb, "search", map[string]string{"query": "go"})
+	client, _ := anthropic.NewClient(anthropic.WithAPIKey("test-key"), anthropic.WithHTTPClient(rt.HTTPClient()))
+
+	rt.AssertToolResult("results")
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "expected a request following the tool call") {
+		t.Errorf("Expected a missing follow-up to fail the test, got %v", tb.errors)
+	}
+
+	params := &anthropic.MessageParams{
+		Model:    string(anthropic.ModelHaiku),
+		Messages: []anthropic.MessageParam{{Role: "user", Content: []anthropic.ContentBlock{anthropic.TextContent("Search for go.")}}},
+	}
+	message, err := client.Messages().Create(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	params.Messages = append(params.Messages,
+		message.ToParam(),
+		anthropic.MessageParam{Role: "user", Content: []anthropic.ContentBlock{anthropic.ToolResultContent(DefaultToolUseID, "no results", false)}},
+	)
+	if _, err := client.Messages().Create(context.Background(), params); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tb.errors = nil
+	rt.AssertToolResult("results")
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], `expected tool_result output "results", got "no results"`) {
+		t.Errorf("Expected a wrong output to fail the test, got %v", tb.errors)
+	}
+}
+
+func TestSplitRunes(t *testing.T) {
+	pieces := splitRunes(`{"city":"Zürich"}`, 4)
+	if strings.Join(pieces, "") != `{"city":"Zürich"}` {
+		t.Errorf("Expected the pieces to rejoin into the input, got %q", pieces)
+	}
+	for _, piece := range pieces {
+		if len([]rune(piece)) > 4 {
+			t.Errorf("Expected pieces of at most 4 characters, got %q", piece)
+		}
+	}
+}
+
+func TestToolRoundTripAssertToolResultWireFormat(t *testing.T) {
+	tb := &fakeTB{TB: t, name: "TestToolRoundTrip"}
+	rt := NewToolRoundTrip(tb, "search", map[string]string{"query": "go"})
+	client := rt.HTTPClient()
+
+	// A tool result nested under tool_output, rather than in the API's wire format.
+	for _, body := range []string{
+		`{"model":"m","messages":[{"role":"user","content":"Search for go."}]}`,
+		`{"model":"m","messages":[{"role":"user","content":[{"type":"tool_result","tool_output":{"tool_call_id":"toolu_anthropictest","output":"results"}}]}]}`,
+	} {
+		resp, err := client.Post("https://api.anthropic.com/v1/messages", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	rt.AssertToolResult("results")
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "tool_use_id") {
+		t.Errorf("Expected a tool result outside the wire format to fail the test, got %v", tb.errors)
+	}
+}
+
+func TestToolRoundTripCachedSystemPrompt(t *testing.T) {
+	rt := NewToolRoundTrip(t, "search", map[string]string{"query": "go"})
+	client, err := anthropic.NewClient(
+		anthropic.WithAPIKey("test-key"),
+		anthropic.WithHTTPClient(rt.HTTPClient()),
+		anthropic.WithCachedSystemPrompt("You are a search assistant."),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	params := &anthropic.MessageParams{
+		Model:    string(anthropic.ModelHaiku),
+		Messages: []anthropic.MessageParam{{Role: "user", Content: []anthropic.ContentBlock{anthropic.TextContent("Search for go.")}}},
+	}
+	message, err := client.Messages().Create(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	params.Messages = append(params.Messages,
+		message.ToParam(),
+		anthropic.MessageParam{Role: "user", Content: []anthropic.ContentBlock{anthropic.ToolResultContent(DefaultToolUseID, "results", false)}},
+	)
+	if _, err := client.Messages().Create(context.Background(), params); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rt.AssertToolResult("results")
+}
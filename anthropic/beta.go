@@ -0,0 +1,44 @@
+package anthropic
+
+// Beta features that can be enabled with WithBetaFeatures or MessageParams.BetaFeatures.
+// The SDK enables some of them itself when a request needs them, as noted.
+const (
+	// BetaThinking enables interleaved thinking between tool calls.
+	BetaThinking = "interleaved-thinking-2025-05-14"
+	// BetaPDFs enables PDF document content blocks.
+	BetaPDFs = "pdfs-2024-09-25"
+	// BetaPromptCaching enables prompt caching with cache_control.
+	BetaPromptCaching = "prompt-caching-2024-07-31"
+	// BetaContext1M enables the 1M token context window on supported models.
+	BetaContext1M = "context-1m-2025-08-07"
+	// BetaFineGrainedToolStreaming streams tool inputs without buffering them
+	// server-side. Enabled by WithFineGrainedToolStreaming.
+	BetaFineGrainedToolStreaming = "fine-grained-tool-streaming-2025-05-14"
+	// BetaFilesAPI enables the Files API. Enabled by FilesService.
+	BetaFilesAPI = "files-api-2025-04-14"
+	// BetaWebSearch enables the server-side web search tool. Enabled for requests
+	// using a WebSearchTool.
+	BetaWebSearch = "web-search-2025-03-05"
+	// BetaMaxTokens35Sonnet raises the output limit of Claude 3.5 Sonnet to 8192
+	// tokens. Enabled for requests whose max_tokens need it.
+	BetaMaxTokens35Sonnet = "max-tokens-3-5-sonnet-2024-07-15"
+	// BetaOutput128K raises the output limit of Claude 3.7 Sonnet to 128K tokens.
+	// Enabled for requests whose max_tokens need it.
+	BetaOutput128K = "output-128k-2025-02-19"
+)
+
+// SupportedBetaFeatures returns the beta features known to this package. Others can
+// still be enabled by passing their header value.
+func SupportedBetaFeatures() []string {
+	return []string{
+		BetaThinking,
+		BetaPDFs,
+		BetaPromptCaching,
+		BetaContext1M,
+		BetaFineGrainedToolStreaming,
+		BetaFilesAPI,
+		BetaWebSearch,
+		BetaMaxTokens35Sonnet,
+		BetaOutput128K,
+	}
+}
@@ -0,0 +1,28 @@
+package anthropic
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestSupportedBetaFeatures(t *testing.T) {
+	dated := regexp.MustCompile(`^[a-z0-9-]+-\d{4}-\d{2}-\d{2}$`)
+	seen := map[string]bool{}
+	for _, beta := range SupportedBetaFeatures() {
+		if !dated.MatchString(beta) {
+			t.Errorf("Expected a date-stamped beta header value, got %q", beta)
+		}
+		if seen[beta] {
+			t.Errorf("Expected %q to be listed once", beta)
+		}
+		seen[beta] = true
+	}
+	for _, beta := range maxTokensBetas {
+		if !seen[beta.Beta] {
+			t.Errorf("Expected the max tokens beta %q to be listed", beta.Beta)
+		}
+	}
+	if !seen[BetaThinking] || !seen[BetaContext1M] {
+		t.Errorf("Expected BetaThinking and BetaContext1M to be listed, got %v", SupportedBetaFeatures())
+	}
+}
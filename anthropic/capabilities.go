@@ -0,0 +1,119 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// modelCatalog lists the known models and the features they support.
+var modelCatalog = []Model{
+	{ID: ModelHaiku, Name: "Claude 3 Haiku", SupportsVision: true, SupportsTools: true, MaxOutputTokens: 4096},
+	{ID: ModelSonnet, Name: "Claude 3 Sonnet", SupportsVision: true, SupportsTools: true, MaxOutputTokens: 4096},
+	{ID: ModelOpus, Name: "Claude 3 Opus", SupportsVision: true, SupportsTools: true, MaxOutputTokens: 4096},
+	{ID: ModelSonnet35, Name: "Claude 3.5 Sonnet", SupportsVision: true, SupportsTools: true, SupportsPDF: true, MaxOutputTokens: 8192},
+	{ID: ModelSonnet37, Name: "Claude 3.7 Sonnet", SupportsVision: true, SupportsTools: true, SupportsThinking: true, SupportsPDF: true, MaxOutputTokens: 128000},
+}
+
+// GetModel returns the metadata of a known model.
+func GetModel(id ModelID) (Model, bool) {
+	for _, model := range modelCatalog {
+		if model.ID == id {
+			return model, true
+		}
+	}
+	return Model{}, false
+}
+
+// ErrUnsupportedCapability is returned, when WithStrictCapabilities is set, for a
+// request that uses a feature the chosen model does not support.
+var ErrUnsupportedCapability = errors.New("model does not support a requested capability")
+
+// WithStrictCapabilities makes the client reject, with an error wrapping
+// ErrUnsupportedCapability, requests that use images, PDFs, tools or thinking with a
+// known model that does not support them. By default such requests are sent after
+// logging a warning. Requests for models without metadata are never checked.
+func WithStrictCapabilities() ClientOption {
+	return func(c *Client) error {
+		c.strictCapabilities = true
+		return nil
+	}
+}
+
+// unsupportedCapabilities returns the features used by params that the model does
+// not support, or nil if the model is unknown.
+func unsupportedCapabilities(params *MessageParams) []string {
+	model, ok := GetModel(ModelID(params.Model))
+	if !ok {
+		return nil
+	}
+
+	usesImages, usesPDF := false, false
+	for _, message := range params.Messages {
+		for _, block := range message.Content {
+			switch block.Type {
+			case "image":
+				usesImages = true
+			case "document":
+				usesPDF = true
+			}
+		}
+	}
+
+	var unsupported []string
+	if usesImages && !model.SupportsVision {
+		unsupported = append(unsupported, "vision")
+	}
+	if usesPDF && !model.SupportsPDF {
+		unsupported = append(unsupported, "PDF")
+	}
+	if len(params.Tools) > 0 && !model.SupportsTools {
+		unsupported = append(unsupported, "tools")
+	}
+	if params.Thinking != nil && params.Thinking.Type == "enabled" && !model.SupportsThinking {
+		unsupported = append(unsupported, "thinking")
+	}
+	return unsupported
+}
+
+// checkCapabilities warns about, or with WithStrictCapabilities rejects, requests that
+// use features the model does not support.
+func (c *Client) checkCapabilities(ctx context.Context, params *MessageParams) error {
+	unsupported := unsupportedCapabilities(params)
+	if len(unsupported) == 0 {
+		return nil
+	}
+	if c.strictCapabilities {
+		return fmt.Errorf("%s does not support %s: %w", params.Model, strings.Join(unsupported, ", "), ErrUnsupportedCapability)
+	}
+	c.log(ctx, LogLevelWarn, "model does not support requested capabilities", "model", params.Model, "capabilities", unsupported)
+	return nil
+}
+
+// WithClampMaxTokens makes the client lower a request's max_tokens to the model's
+// MaxOutputTokens, logging a warning, instead of sending a value the API rejects. This
+// smooths over switching to a model with a lower output limit. Requests for models
+// without metadata are sent unchanged.
+func WithClampMaxTokens() ClientOption {
+	return func(c *Client) error {
+		c.clampMaxTokens = true
+		return nil
+	}
+}
+
+// withClampedMaxTokens returns params with max_tokens lowered to the model's output
+// limit when WithClampMaxTokens is set, or params unchanged if it is within the limit.
+func (c *Client) withClampedMaxTokens(ctx context.Context, params *MessageParams) *MessageParams {
+	if !c.clampMaxTokens {
+		return params
+	}
+	model, ok := GetModel(ModelID(params.Model))
+	if !ok || model.MaxOutputTokens == 0 || params.MaxTokens <= model.MaxOutputTokens {
+		return params
+	}
+	c.log(ctx, LogLevelWarn, "max_tokens exceeds the model's limit, clamping", "model", params.Model, "max_tokens", params.MaxTokens, "limit", model.MaxOutputTokens)
+	clamped := *params
+	clamped.MaxTokens = model.MaxOutputTokens
+	return &clamped
+}
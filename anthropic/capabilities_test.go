@@ -0,0 +1,154 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnsupportedCapabilities(t *testing.T) {
+	image := ContentBlock{Type: "image", Source: &Image{Type: "base64", MediaType: MediaTypePNG, Data: "aGk="}}
+	document := ContentBlock{Type: "document", Source: &Image{Type: "base64", MediaType: "application/pdf", Data: "aGk="}}
+	withContent := func(blocks ...ContentBlock) []MessageParam {
+		return []MessageParam{{Role: "user", Content: blocks}}
+	}
+
+	testCases := []struct {
+		name     string
+		params   *MessageParams
+		expected []string
+	}{
+		{name: "Plain text", params: &MessageParams{Model: string(ModelHaiku), Messages: withContent(ContentBlock{Type: "text", Text: "hi"})}},
+		{name: "Image on vision model", params: &MessageParams{Model: string(ModelHaiku), Messages: withContent(image)}},
+		{name: "PDF on Claude 3", params: &MessageParams{Model: string(ModelOpus), Messages: withContent(document)}, expected: []string{"PDF"}},
+		{name: "PDF on Claude 3.5 Sonnet", params: &MessageParams{Model: string(ModelSonnet35), Messages: withContent(document)}},
+		{
+			name:     "Thinking on Claude 3",
+			params:   &MessageParams{Model: string(ModelSonnet), Thinking: &ThinkingConfig{Type: "enabled", BudgetTokens: 1024}},
+			expected: []string{"thinking"},
+		},
+		{name: "Disabled thinking", params: &MessageParams{Model: string(ModelSonnet), Thinking: &ThinkingConfig{Type: "disabled"}}},
+		{name: "Thinking on Claude 3.7 Sonnet", params: &MessageParams{Model: string(ModelSonnet37), Thinking: &ThinkingConfig{Type: "enabled", BudgetTokens: 1024}}},
+		{
+			name:   "Unknown model",
+			params: &MessageParams{Model: "claude-next", Messages: withContent(document), Thinking: &ThinkingConfig{Type: "enabled"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := unsupportedCapabilities(tc.params); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestCapabilityCheck(t *testing.T) {
+	var requests int
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		requests++
+		return testResponse{body: `{"id":"msg_123","role":"assistant","content":[]}`}
+	})
+	defer server.Close()
+
+	params := &MessageParams{
+		Model:    string(ModelHaiku),
+		Messages: []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Think hard."}}}},
+		Thinking: &ThinkingConfig{Type: "enabled", BudgetTokens: 1024},
+	}
+
+	var buf bytes.Buffer
+	lenient, _ := NewClient(
+		WithAPIKey("test-key"),
+		WithBaseURL(server.URL),
+		WithSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))),
+	)
+	if _, err := lenient.Messages().Create(context.Background(), params); err != nil {
+		t.Fatalf("Expected the request to be sent with a warning, got %v", err)
+	}
+	if requests != 1 || !strings.Contains(buf.String(), "model does not support requested capabilities") {
+		t.Errorf("Expected a warning and a sent request, got %d requests and log %s", requests, buf.String())
+	}
+
+	strict, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL), WithStrictCapabilities())
+	_, err := strict.Messages().Create(context.Background(), params)
+	if !errors.Is(err, ErrUnsupportedCapability) || !strings.Contains(err.Error(), "thinking") {
+		t.Errorf("Expected ErrUnsupportedCapability for thinking, got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected the strict client not to send the request")
+	}
+}
+
+func TestGetModel(t *testing.T) {
+	model, ok := GetModel(ModelSonnet37)
+	if !ok || !model.SupportsThinking || model.Name != "Claude 3.7 Sonnet" {
+		t.Errorf("Unexpected metadata for %s: %+v", ModelSonnet37, model)
+	}
+	if _, ok := GetModel("claude-next"); ok {
+		t.Errorf("Expected no metadata for an unknown model")
+	}
+}
+
+func TestWithClampMaxTokens(t *testing.T) {
+	var sent []int
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		var body MessageParams
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		sent = append(sent, body.MaxTokens)
+		return testResponse{body: `{"id":"msg_123","role":"assistant","content":[]}`}
+	})
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client, _ := NewClient(
+		WithAPIKey("test-key"),
+		WithBaseURL(server.URL),
+		WithClampMaxTokens(),
+		WithSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))),
+	)
+	messages := []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Hi"}}}}
+
+	testCases := []struct {
+		name      string
+		model     ModelID
+		maxTokens int
+		expected  int
+	}{
+		{name: "Above limit", model: ModelHaiku, maxTokens: 8192, expected: 4096},
+		{name: "At limit", model: ModelSonnet35, maxTokens: 8192, expected: 8192},
+		{name: "Unknown model", model: "claude-next", maxTokens: 200000, expected: 200000},
+	}
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params := &MessageParams{Model: string(tc.model), Messages: messages, MaxTokens: tc.maxTokens}
+			if _, err := client.Messages().Create(context.Background(), params); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if sent[i] != tc.expected {
+				t.Errorf("Expected max_tokens %d, got %d", tc.expected, sent[i])
+			}
+			if params.MaxTokens != tc.maxTokens {
+				t.Errorf("Expected the params not to be modified, got max_tokens %d", params.MaxTokens)
+			}
+		})
+	}
+	if strings.Count(buf.String(), "clamping") != 1 {
+		t.Errorf("Expected one clamping warning, got log %s", buf.String())
+	}
+
+	unclamped, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	if _, err := unclamped.Messages().Create(context.Background(), &MessageParams{Model: string(ModelHaiku), Messages: messages, MaxTokens: 8192}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if sent[len(sent)-1] != 8192 {
+		t.Errorf("Expected max_tokens to be sent unchanged without the option, got %d", sent[len(sent)-1])
+	}
+}
@@ -16,11 +16,19 @@ Key components:
 */
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
 	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
+	"unicode"
 )
 
 const (
@@ -36,6 +44,34 @@ type Client struct {
 	APIKey     string
 	APIVersion string
 	httpClient *http.Client
+	logger     Logger
+
+	fallbackModels         []ModelID
+	requestEditors         []RequestEditor
+	headers                http.Header
+	maxRetries             int
+	retryBaseDelay         time.Duration
+	retryMaxDelay          time.Duration
+	forceHTTP1             bool
+	allowInsecure          bool
+	maxResponseBytes       int64
+	maxStreamBytes         int64
+	onUnknownModel         func(model string)
+	modelRouter            func(*MessageParams) ModelID
+	betaFeatures           []string
+	cachedSystem           string
+	strictToolResults      bool
+	strictCapabilities     bool
+	clampMaxTokens         bool
+	defaultTemperature     *float64
+	defaultTopP            *float64
+	defaultTopK            *int
+	streamSplit            bufio.SplitFunc
+	omitStreamFalse        bool
+	countTokensConcurrency int
+	apiKeyFunc             *apiKeyCache
+	idGenerator            func() string
+	jitter                 *lockedRand
 }
 
 // ClientOption is a function that modifies a Client.
@@ -49,6 +85,14 @@ func NewClient(opts ...ClientOption) (*Client, error) {
 		httpClient: &http.Client{
 			Timeout: defaultTimeout,
 		},
+		logger:                 noopLogger{},
+		retryBaseDelay:         defaultRetryBaseDelay,
+		retryMaxDelay:          defaultRetryMaxDelay,
+		maxResponseBytes:       defaultMaxResponseBytes,
+		maxStreamBytes:         defaultMaxStreamBytes,
+		countTokensConcurrency: defaultCountTokensConcurrency,
+		idGenerator:            newUUID,
+		jitter:                 newLockedRand(rand.NewSource(time.Now().UnixNano())),
 	}
 
 	for _, opt := range opts {
@@ -58,14 +102,39 @@ func NewClient(opts ...ClientOption) (*Client, error) {
 	}
 
 	// Check if API key is set
-	if client.APIKey == "" {
+	if client.APIKey == "" && client.apiKeyFunc == nil {
 		return nil, fmt.Errorf("API key is required")
 	}
 
+	if client.APIKey != "" {
+		if problem := checkAPIKeyFormat(client.APIKey); problem != "" {
+			client.log(context.Background(), LogLevelWarn, "API key looks malformed", "problem", problem)
+		}
+	}
+
+	if client.forceHTTP1 {
+		if err := client.disableHTTP2(); err != nil {
+			return nil, err
+		}
+	}
+
+	if normalized := normalizeBaseURL(client.baseURL); normalized != client.baseURL {
+		client.log(context.Background(), LogLevelWarn, "normalized base URL", "from", client.baseURL, "to", normalized)
+		client.baseURL = normalized
+	}
+
+	if !client.allowInsecure {
+		if err := checkBaseURLScheme(client.baseURL); err != nil {
+			return nil, err
+		}
+	}
+
 	return client, nil
 }
 
 // WithBaseURL sets a custom base URL for the client.
+// The URL must include the API version path (e.g. "https://api.anthropic.com/v1").
+// A trailing slash or a duplicated version segment such as "/v1/v1" is collapsed.
 func WithBaseURL(url string) ClientOption {
 	return func(c *Client) error {
 		c.baseURL = url
@@ -73,11 +142,15 @@ func WithBaseURL(url string) ClientOption {
 	}
 }
 
-// WithAPIKey sets the API key for the client.
+// WithAPIKey sets the API key for the client, trimming surrounding whitespace such as
+// a trailing newline read from a file. If apiKey is empty, the ANTHROPIC_API_KEY
+// environment variable is used. A key without the "sk-ant-" prefix is accepted, as
+// gateways issue their own keys, but NewClient logs a warning about it.
 func WithAPIKey(apiKey string) ClientOption {
 	return func(c *Client) error {
+		apiKey = strings.TrimSpace(apiKey)
 		if apiKey == "" {
-			apiKey = os.Getenv(envAPIKey)
+			apiKey = strings.TrimSpace(os.Getenv(envAPIKey))
 		}
 		if apiKey == "" {
 			return fmt.Errorf("no API key provided and %s environment variable is not set", envAPIKey)
@@ -87,6 +160,21 @@ func WithAPIKey(apiKey string) ClientOption {
 	}
 }
 
+// apiKeyPrefix is the prefix of the API keys issued by Anthropic.
+const apiKeyPrefix = "sk-ant-"
+
+// checkAPIKeyFormat describes what is unusual about apiKey for an Anthropic API key,
+// or returns "" if nothing is. It never includes the key itself.
+func checkAPIKeyFormat(apiKey string) string {
+	if strings.ContainsFunc(apiKey, unicode.IsSpace) {
+		return "contains whitespace"
+	}
+	if !strings.HasPrefix(apiKey, apiKeyPrefix) {
+		return "does not start with " + apiKeyPrefix
+	}
+	return ""
+}
+
 // WithAPIVersion sets a custom API version for the client.
 func WithAPIVersion(version string) ClientOption {
 	return func(c *Client) error {
@@ -112,6 +200,269 @@ func WithTimeout(timeout time.Duration) ClientOption {
 	}
 }
 
+// WithLogger sets the logger used by the client.
+// Request and response bodies are only logged at LogLevelDebug.
+func WithLogger(logger Logger) ClientOption {
+	return func(c *Client) error {
+		if logger == nil {
+			logger = noopLogger{}
+		}
+		c.logger = logger
+		return nil
+	}
+}
+
+// WithSlogLogger sets an slog-backed logger for the client.
+func WithSlogLogger(logger *slog.Logger) ClientOption {
+	return WithLogger(NewSlogLogger(logger))
+}
+
+// WithModelFallback sets a chain of models to try, in order, when the requested model
+// is overloaded or unavailable. All other request parameters are preserved, and the
+// returned Message reports the model that actually served the response.
+func WithModelFallback(models ...ModelID) ClientOption {
+	return func(c *Client) error {
+		c.fallbackModels = models
+		return nil
+	}
+}
+
+// normalizeBaseURL trims trailing slashes and collapses repeated version path
+// segments, so that "https://api.anthropic.com/v1/v1/" becomes "https://api.anthropic.com/v1".
+func normalizeBaseURL(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Path == "" {
+		return strings.TrimRight(baseURL, "/")
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	kept := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if len(kept) > 0 && segment == kept[len(kept)-1] && isVersionSegment(segment) {
+			continue
+		}
+		kept = append(kept, segment)
+	}
+	u.Path = "/" + strings.Join(kept, "/")
+	if u.Path == "/" {
+		u.Path = ""
+	}
+	return u.String()
+}
+
+// isVersionSegment reports whether a path segment looks like an API version, e.g. "v1".
+func isVersionSegment(segment string) bool {
+	if len(segment) < 2 || segment[0] != 'v' {
+		return false
+	}
+	for _, r := range segment[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// WithCachedSystemPrompt sets a system prompt that is sent with every request that
+// does not set its own, as a text block marked with ephemeral cache_control so that
+// the stable preamble is served from the prompt cache.
+func WithCachedSystemPrompt(text string) ClientOption {
+	return func(c *Client) error {
+		c.cachedSystem = text
+		return nil
+	}
+}
+
+// withCachedSystemPrompt returns params with the client's cached system prompt set,
+// or params unchanged if there is none or the request sets its own system prompt.
+func (c *Client) withCachedSystemPrompt(params *MessageParams) *MessageParams {
+	if c.cachedSystem == "" || params.systemPrompt() != nil {
+		return params
+	}
+	withSystem := *params
+	withSystem.SystemBlocks = []SystemBlock{{
+		Type:         "text",
+		Text:         c.cachedSystem,
+		CacheControl: &CacheControlParam{Type: CacheControlEphemeral},
+	}}
+	return &withSystem
+}
+
+// WithStrictToolResultOrdering makes the client check, before sending a messages
+// request, that every assistant turn's tool_use blocks are answered by exactly the
+// tool_result blocks of the following turn, as checked by ValidateToolResults.
+func WithStrictToolResultOrdering() ClientOption {
+	return func(c *Client) error {
+		c.strictToolResults = true
+		return nil
+	}
+}
+
+// WithHeader sets a header on every request, replacing the value the SDK would send,
+// e.g. to satisfy a gateway that requires a specific Accept header. Calling it again
+// with the same key adds another value. Overriding Accept does not change how the
+// response is parsed: requests for which MessageParams.IsStreaming reports true are
+// still parsed as event streams.
+//
+// An anthropic-beta header is merged with the betas the SDK sends instead of
+// replacing them. The x-api-key and anthropic-version headers are rejected; use
+// WithAPIKey and WithAPIVersion instead.
+func WithHeader(key, value string) ClientOption {
+	return func(c *Client) error {
+		switch http.CanonicalHeaderKey(key) {
+		case "X-Api-Key":
+			return fmt.Errorf("header %s must be set with WithAPIKey", key)
+		case "Anthropic-Version":
+			return fmt.Errorf("header %s must be set with WithAPIVersion", key)
+		}
+		if c.headers == nil {
+			c.headers = make(http.Header)
+		}
+		c.headers.Add(key, value)
+		return nil
+	}
+}
+
+// WithBetaFeatures enables the given beta features on every messages request, via the
+// anthropic-beta header. They are combined with MessageParams.BetaFeatures and with
+// the betas the SDK adds automatically, without duplicates. Known betas have constants,
+// such as BetaPDFs, listed by SupportedBetaFeatures.
+func WithBetaFeatures(betas ...string) ClientOption {
+	return func(c *Client) error {
+		c.betaFeatures = append(c.betaFeatures, betas...)
+		return nil
+	}
+}
+
+// WithFineGrainedToolStreaming enables the fine-grained tool streaming beta, in which
+// the API streams tool inputs as they are generated instead of buffering and
+// validating each JSON value first. Fragments reach MessageParams.ToolInputDeltaFunc
+// with lower latency, at the cost that a tool call's input may be partial or invalid
+// JSON until the stream stops, and may stay invalid if generation is cut short, e.g.
+// by max_tokens. In this mode, streamed messages with invalid tool input are returned
+// as is instead of failing with an *IncompleteToolInputError.
+func WithFineGrainedToolStreaming() ClientOption {
+	return func(c *Client) error {
+		c.betaFeatures = append(c.betaFeatures, BetaFineGrainedToolStreaming)
+		return nil
+	}
+}
+
+// WithOmitStreamFalse leaves the "stream" field out of the body of non-streaming
+// requests instead of sending "stream": false, for strict gateways that reject
+// fields they do not expect. Streaming requests still send "stream": true.
+func WithOmitStreamFalse() ClientOption {
+	return func(c *Client) error {
+		c.omitStreamFalse = true
+		return nil
+	}
+}
+
+// WithStreamSplitFunc replaces the function that splits a streaming response body
+// into lines, for intermediaries that frame events in non-standard ways, such as
+// length-prefixed frames. Each token the function returns is handled as one line of
+// a server-sent event stream: an "event:" or "data:" field, or an empty line ending
+// the event. The default splits at "\n", "\r\n" and "\r", as the SSE format allows.
+func WithStreamSplitFunc(split bufio.SplitFunc) ClientOption {
+	return func(c *Client) error {
+		if split == nil {
+			return fmt.Errorf("stream split func must not be nil")
+		}
+		c.streamSplit = split
+		return nil
+	}
+}
+
+// WithModelRouter sets a function that chooses the model for requests that leave
+// MessageParams.Model empty, e.g. a cheaper model for short prompts. It is called
+// before the request is validated and must not modify params; the caller's params
+// are not changed by the routing decision.
+func WithModelRouter(router func(*MessageParams) ModelID) ClientOption {
+	return func(c *Client) error {
+		c.modelRouter = router
+		return nil
+	}
+}
+
+// WithUnknownModelCallback sets a function that is called with the model of any
+// response whose model is not one of the ModelID constants of this package, for
+// example a new snapshot. It is called synchronously, so it should return quickly.
+func WithUnknownModelCallback(fn func(model string)) ClientOption {
+	return func(c *Client) error {
+		c.onUnknownModel = fn
+		return nil
+	}
+}
+
+// WithAllowInsecureHTTP allows a plain http base URL on a non-loopback host.
+// By default NewClient rejects such URLs, since the API key would be sent unencrypted.
+// It is intended for local testing only.
+func WithAllowInsecureHTTP() ClientOption {
+	return func(c *Client) error {
+		c.allowInsecure = true
+		return nil
+	}
+}
+
+// checkBaseURLScheme returns an error unless baseURL uses https or points at a
+// loopback host, so that API keys are never sent in plaintext over the network.
+func checkBaseURLScheme(baseURL string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return fmt.Errorf("invalid base URL %q: %w", baseURL, err)
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if isLoopbackHost(u.Hostname()) {
+			return nil
+		}
+		return fmt.Errorf("base URL %q uses plain http, which would send the API key unencrypted; use https or WithAllowInsecureHTTP", baseURL)
+	default:
+		return fmt.Errorf("base URL %q must use https", baseURL)
+	}
+}
+
+// isLoopbackHost reports whether host is localhost or a loopback IP address.
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// WithForceHTTP1 disables HTTP/2 on the client's transport, as a workaround for
+// proxies that break HTTP/2 streaming. It applies to the default transport and to a
+// custom *http.Transport set with WithHTTPClient; the latter is cloned, not modified.
+func WithForceHTTP1() ClientOption {
+	return func(c *Client) error {
+		c.forceHTTP1 = true
+		return nil
+	}
+}
+
+// disableHTTP2 replaces the HTTP client's transport with a copy that only speaks HTTP/1.1.
+func (c *Client) disableHTTP2() error {
+	var transport *http.Transport
+	switch t := c.httpClient.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return fmt.Errorf("cannot force HTTP/1.1 on a custom transport of type %T", t)
+	}
+	transport.ForceAttemptHTTP2 = false
+	transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+
+	httpClient := *c.httpClient
+	httpClient.Transport = transport
+	c.httpClient = &httpClient
+	return nil
+}
+
 // SetAPIKey updates the API key for the client.
 func (c *Client) SetAPIKey(apiKey string) {
 	c.APIKey = apiKey
@@ -127,6 +478,11 @@ func (c *Client) Messages() *MessagesService {
 	return &MessagesService{client: c}
 }
 
+// Files returns a new FilesService.
+func (c *Client) Files() *FilesService {
+	return &FilesService{client: c}
+}
+
 // ModelsService handles operations related to models.
 type ModelsService struct {
 	client *Client
@@ -137,13 +493,14 @@ type MessagesService struct {
 	client *Client
 }
 
+// FilesService handles operations related to the beta Files API.
+type FilesService struct {
+	client *Client
+}
+
 // List retrieves a list of available models.
 func (s *ModelsService) List() ([]Model, error) {
-	return []Model{
-		{ID: ModelHaiku, Name: "Claude 3 Haiku"},
-		{ID: ModelSonnet, Name: "Claude 3 Sonnet"},
-		{ID: ModelOpus, Name: "Claude 3 Opus"},
-	}, nil
+	return append([]Model(nil), modelCatalog...), nil
 }
 
 // GetModelID returns the ModelID for a given name.
@@ -153,6 +510,10 @@ func GetModelID(name string) (ModelID, bool) {
 		return ModelHaiku, true
 	case "SONNET":
 		return ModelSonnet, true
+	case "SONNET_35":
+		return ModelSonnet35, true
+	case "SONNET_37":
+		return ModelSonnet37, true
 	case "OPUS":
 		return ModelOpus, true
 	default:
@@ -164,3 +525,23 @@ func GetModelID(name string) (ModelID, bool) {
 func (s *MessagesService) Create(ctx context.Context, params *MessageParams) (*Message, error) {
 	return s.client.Create(ctx, params)
 }
+
+// Complete sends a single user prompt to the model and returns the text of the reply.
+// It is a shortcut for building MessageParams, calling Create, and concatenating the
+// text content blocks of the response.
+func (s *MessagesService) Complete(ctx context.Context, model ModelID, prompt string, maxTokens int) (string, error) {
+	message, err := s.Create(ctx, &MessageParams{
+		Model:     string(model),
+		MaxTokens: maxTokens,
+		Messages: []MessageParam{
+			{
+				Role:    "user",
+				Content: []ContentBlock{TextContent(prompt)},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return message.Text(), nil
+}
@@ -1,6 +1,13 @@
 package anthropic
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -50,9 +57,11 @@ func TestModelsService_List(t *testing.T) {
     }
 
     expectedModels := []Model{
-        {ID: ModelHaiku, Name: "Claude 3 Haiku"},
-        {ID: ModelSonnet, Name: "Claude 3 Sonnet"},
-        {ID: ModelOpus, Name: "Claude 3 Opus"},
+        {ID: ModelHaiku, Name: "Claude 3 Haiku", SupportsVision: true, SupportsTools: true, MaxOutputTokens: 4096},
+        {ID: ModelSonnet, Name: "Claude 3 Sonnet", SupportsVision: true, SupportsTools: true, MaxOutputTokens: 4096},
+        {ID: ModelOpus, Name: "Claude 3 Opus", SupportsVision: true, SupportsTools: true, MaxOutputTokens: 4096},
+        {ID: ModelSonnet35, Name: "Claude 3.5 Sonnet", SupportsVision: true, SupportsTools: true, SupportsPDF: true, MaxOutputTokens: 8192},
+        {ID: ModelSonnet37, Name: "Claude 3.7 Sonnet", SupportsVision: true, SupportsTools: true, SupportsThinking: true, SupportsPDF: true, MaxOutputTokens: 128000},
     }
 
     if len(models) != len(expectedModels) {
@@ -65,3 +74,476 @@ func TestModelsService_List(t *testing.T) {
         }
     }
 }
+
+func TestNormalizeBaseURL(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{input: "https://api.anthropic.com/v1", expected: "https://api.anthropic.com/v1"},
+		{input: "https://api.anthropic.com/v1/", expected: "https://api.anthropic.com/v1"},
+		{input: "https://api.anthropic.com/v1/v1", expected: "https://api.anthropic.com/v1"},
+		{input: "https://api.anthropic.com/v1/v1/", expected: "https://api.anthropic.com/v1"},
+		{input: "https://gateway.example.com/anthropic/v1/v1", expected: "https://gateway.example.com/anthropic/v1"},
+		{input: "http://127.0.0.1:8080", expected: "http://127.0.0.1:8080"},
+		{input: "http://127.0.0.1:8080/", expected: "http://127.0.0.1:8080"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			if got := normalizeBaseURL(tc.input); got != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestNewClientNormalizesBaseURL(t *testing.T) {
+	var buf bytes.Buffer
+	client, err := NewClient(
+		WithAPIKey("test-key"),
+		WithBaseURL("https://api.anthropic.com/v1/v1"),
+		WithSlogLogger(slog.New(slog.NewTextHandler(&buf, nil))),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if client.baseURL != defaultBaseURL {
+		t.Errorf("Expected base URL to be '%s', got '%s'", defaultBaseURL, client.baseURL)
+	}
+	if !strings.Contains(buf.String(), "normalized base URL") {
+		t.Errorf("Expected a normalization warning, got %q", buf.String())
+	}
+}
+
+func TestWithForceHTTP1(t *testing.T) {
+	client, err := NewClient(WithAPIKey("test-key"), WithForceHTTP1())
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	transport, ok := client.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expected an *http.Transport, got %T", client.httpClient.Transport)
+	}
+	if transport.ForceAttemptHTTP2 {
+		t.Errorf("Expected ForceAttemptHTTP2 to be false")
+	}
+	if transport.TLSNextProto == nil || len(transport.TLSNextProto) != 0 {
+		t.Errorf("Expected an empty non-nil TLSNextProto map, got %v", transport.TLSNextProto)
+	}
+	if transport == http.DefaultTransport {
+		t.Errorf("Expected the default transport to be cloned, not modified")
+	}
+}
+
+func TestWithForceHTTP1CustomTransport(t *testing.T) {
+	custom := &http.Transport{ForceAttemptHTTP2: true}
+	httpClient := &http.Client{Transport: custom}
+
+	client, err := NewClient(WithAPIKey("test-key"), WithForceHTTP1(), WithHTTPClient(httpClient))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if client.httpClient.Transport.(*http.Transport).ForceAttemptHTTP2 {
+		t.Errorf("Expected HTTP/2 to be disabled on the client transport")
+	}
+	if !custom.ForceAttemptHTTP2 || httpClient.Transport != custom {
+		t.Errorf("Expected the caller's transport and client to be left untouched")
+	}
+
+	_, err = NewClient(WithAPIKey("test-key"), WithForceHTTP1(), WithHTTPClient(&http.Client{Transport: roundTripFunc(nil)}))
+	if err == nil {
+		t.Errorf("Expected an error for a non-*http.Transport round tripper")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestNewClientBaseURLScheme(t *testing.T) {
+	testCases := []struct {
+		name      string
+		baseURL   string
+		opts      []ClientOption
+		expectErr bool
+	}{
+		{name: "HTTPS", baseURL: "https://proxy.example.com/v1"},
+		{name: "HTTP localhost", baseURL: "http://localhost:8080/v1"},
+		{name: "HTTP loopback IPv4", baseURL: "http://127.0.0.1:8080/v1"},
+		{name: "HTTP loopback IPv6", baseURL: "http://[::1]:8080/v1"},
+		{name: "HTTP remote host", baseURL: "http://proxy.example.com/v1", expectErr: true},
+		{name: "Missing scheme", baseURL: "proxy.example.com/v1", expectErr: true},
+		{name: "HTTP remote host allowed", baseURL: "http://proxy.example.com/v1", opts: []ClientOption{WithAllowInsecureHTTP()}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := append([]ClientOption{WithAPIKey("test-key"), WithBaseURL(tc.baseURL)}, tc.opts...)
+			_, err := NewClient(opts...)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error=%v, got %v", tc.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestWithUnknownModelCallback(t *testing.T) {
+	testCases := []struct {
+		name          string
+		responseModel string
+		expected      []string
+	}{
+		{name: "Known model", responseModel: string(ModelSonnet)},
+		{name: "Unknown model", responseModel: "claude-3-sonnet-20991231", expected: []string{"claude-3-sonnet-20991231"}},
+		{name: "Missing model", responseModel: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, respondWith(testResponse{value: Message{ID: "msg_123", Role: "assistant", Model: tc.responseModel}}))
+			defer server.Close()
+
+			var observed []string
+			client, err := NewClient(
+				WithAPIKey("test-key"),
+				WithBaseURL(server.URL),
+				WithUnknownModelCallback(func(model string) { observed = append(observed, model) }),
+			)
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			if _, err := client.Messages().Create(context.Background(), &MessageParams{Model: string(ModelSonnet)}); err != nil {
+				t.Fatalf("Failed to create message: %v", err)
+			}
+			if !reflect.DeepEqual(observed, tc.expected) {
+				t.Errorf("Expected callback calls %v, got %v", tc.expected, observed)
+			}
+		})
+	}
+}
+
+func TestWithModelRouter(t *testing.T) {
+	var requested []string
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		var body MessageParams
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		requested = append(requested, body.Model)
+		return testResponse{value: Message{ID: "msg_123", Role: "assistant"}}
+	})
+	defer server.Close()
+
+	router := func(params *MessageParams) ModelID {
+		if len(params.Messages) > 0 && len(params.Messages[0].Content[0].Text) < 20 {
+			return ModelHaiku
+		}
+		return ModelOpus
+	}
+	client, err := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL), WithModelRouter(router))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	prompt := func(text string) []MessageParam {
+		return []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: text}}}}
+	}
+	testCases := []struct {
+		name     string
+		params   *MessageParams
+		expected string
+	}{
+		{name: "Short prompt", params: &MessageParams{Messages: prompt("Hi")}, expected: string(ModelHaiku)},
+		{name: "Long prompt", params: &MessageParams{Messages: prompt("Summarize the history of the Roman Empire")}, expected: string(ModelOpus)},
+		{name: "Explicit model", params: &MessageParams{Model: string(ModelSonnet), Messages: prompt("Hi")}, expected: string(ModelSonnet)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			original := tc.params.Model
+			message, err := client.Messages().Create(context.Background(), tc.params)
+			if err != nil {
+				t.Fatalf("Failed to create message: %v", err)
+			}
+			if got := requested[len(requested)-1]; got != tc.expected {
+				t.Errorf("Expected request for model %s, got %s", tc.expected, got)
+			}
+			if message.Model != tc.expected {
+				t.Errorf("Expected message model %s, got %s", tc.expected, message.Model)
+			}
+			if tc.params.Model != original {
+				t.Errorf("Expected caller params to be left unchanged, got model %q", tc.params.Model)
+			}
+		})
+	}
+}
+
+func TestWithCachedSystemPrompt(t *testing.T) {
+	var system interface{}
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		system = body["system"]
+		if strings.HasSuffix(r.URL.Path, countTokensEndpoint) {
+			return testResponse{value: TokenCount{InputTokens: 10}}
+		}
+		return testResponse{value: Message{ID: "msg_123", Role: "assistant"}}
+	})
+	defer server.Close()
+
+	client, err := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL), WithCachedSystemPrompt("You answer questions about our docs."))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	messages := []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Hi"}}}}
+	cachedBlocks := []interface{}{map[string]interface{}{
+		"type":          "text",
+		"text":          "You answer questions about our docs.",
+		"cache_control": map[string]interface{}{"type": "ephemeral"},
+	}}
+
+	testCases := []struct {
+		name     string
+		params   *MessageParams
+		expected interface{}
+	}{
+		{name: "Default cached prompt", params: &MessageParams{Model: string(ModelSonnet), Messages: messages}, expected: cachedBlocks},
+		{name: "Call overrides with System", params: &MessageParams{Model: string(ModelSonnet), Messages: messages, System: "Be terse."}, expected: "Be terse."},
+		{
+			name:     "Call overrides with SystemBlocks",
+			params:   &MessageParams{Model: string(ModelSonnet), Messages: messages, SystemBlocks: []SystemBlock{{Type: "text", Text: "Be terse."}}},
+			expected: []interface{}{map[string]interface{}{"type": "text", "text": "Be terse."}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := client.Messages().Create(context.Background(), tc.params); err != nil {
+				t.Fatalf("Failed to create message: %v", err)
+			}
+			if !reflect.DeepEqual(system, tc.expected) {
+				t.Errorf("Expected system %#v, got %#v", tc.expected, system)
+			}
+			if tc.params.System == "" && len(tc.params.SystemBlocks) == 0 && tc.params.systemPrompt() != nil {
+				t.Errorf("Expected caller params to be left unchanged")
+			}
+		})
+	}
+
+	if _, err := client.Messages().CountTokens(context.Background(), &MessageParams{Model: string(ModelSonnet), Messages: messages}); err != nil {
+		t.Fatalf("Failed to count tokens: %v", err)
+	}
+	if !reflect.DeepEqual(system, cachedBlocks) {
+		t.Errorf("Expected count_tokens to include the cached system prompt, got %#v", system)
+	}
+}
+
+func TestWithHeader(t *testing.T) {
+	var accept []string
+	var gateway string
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		accept = r.Header.Values("Accept")
+		gateway = r.Header.Get("X-Gateway-Route")
+		if strings.Contains(r.URL.Path, "count_tokens") {
+			return testResponse{body: `{"input_tokens":3}`}
+		}
+		// The gateway ignores Accept; the response format follows the request body.
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] == true {
+			return testResponse{events: helloStreamEvents}
+		}
+		return testResponse{value: Message{ID: "msg_123", Role: "assistant", Content: []ContentBlock{{Type: "text", Text: "Hi"}}}}
+	})
+	defer server.Close()
+
+	client, err := NewClient(
+		WithAPIKey("test-key"),
+		WithBaseURL(server.URL),
+		WithHeader("Accept", "application/vnd.gateway+json"),
+		WithHeader("Accept", "*/*"),
+		WithHeader("X-Gateway-Route", "anthropic"),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	expectedAccept := []string{"application/vnd.gateway+json", "*/*"}
+
+	message, err := client.Messages().Create(context.Background(), &MessageParams{Model: string(ModelSonnet)})
+	if err != nil || message.Text() != "Hi" {
+		t.Fatalf("Expected a non-streaming message, got %+v (error %v)", message, err)
+	}
+	if !reflect.DeepEqual(accept, expectedAccept) || gateway != "anthropic" {
+		t.Errorf("Expected overridden headers, got Accept %q and X-Gateway-Route %q", accept, gateway)
+	}
+
+	var streamed string
+	message, err = client.Messages().Create(context.Background(), &MessageParams{
+		Model: string(ModelSonnet),
+		StreamFunc: func(ctx context.Context, chunk []byte) error {
+			streamed += string(chunk)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Expected the stream to be parsed despite the Accept override, got %v", err)
+	}
+	if streamed != "Hello, world!" || message.Text() != "Hello, world!" {
+		t.Errorf("Expected streamed text %q, got %q (message %q)", "Hello, world!", streamed, message.Text())
+	}
+	if !reflect.DeepEqual(accept, expectedAccept) {
+		t.Errorf("Expected overridden Accept on the streaming request, got %q", accept)
+	}
+
+	if _, err := client.Messages().CountTokens(context.Background(), &MessageParams{Model: string(ModelSonnet)}); err != nil {
+		t.Fatalf("Failed to count tokens: %v", err)
+	}
+	if gateway != "anthropic" {
+		t.Errorf("Expected custom headers on count_tokens requests, got %q", gateway)
+	}
+}
+
+func TestWithOmitStreamFalse(t *testing.T) {
+	var body map[string]interface{}
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		body = nil
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] == true {
+			return testResponse{events: helloStreamEvents}
+		}
+		return testResponse{body: `{"id":"msg_123","role":"assistant","content":[]}`}
+	})
+	defer server.Close()
+
+	streamFunc := func(ctx context.Context, chunk []byte) error { return nil }
+	testCases := []struct {
+		name       string
+		opts       []ClientOption
+		streamFunc StreamFunc
+		expected   interface{}
+	}{
+		{name: "Default non-streaming", expected: false},
+		{name: "Omitted non-streaming", opts: []ClientOption{WithOmitStreamFalse()}, expected: nil},
+		{name: "Omitted streaming", opts: []ClientOption{WithOmitStreamFalse()}, streamFunc: streamFunc, expected: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, _ := NewClient(append([]ClientOption{WithAPIKey("test-key"), WithBaseURL(server.URL)}, tc.opts...)...)
+			params := &MessageParams{Model: string(ModelSonnet), StreamFunc: tc.streamFunc}
+			if _, err := client.Messages().Create(context.Background(), params); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			stream, ok := body["stream"]
+			if tc.expected == nil && ok {
+				t.Errorf("Expected no stream field, got %v", stream)
+			}
+			if tc.expected != nil && stream != tc.expected {
+				t.Errorf("Expected stream %v, got %v", tc.expected, stream)
+			}
+		})
+	}
+}
+
+func TestWithAPIKeyFormat(t *testing.T) {
+	testCases := []struct {
+		name     string
+		apiKey   string
+		expected string
+		warning  string
+	}{
+		{name: "Anthropic key", apiKey: "sk-ant-api03-abc", expected: "sk-ant-api03-abc"},
+		{name: "Trailing newline", apiKey: "sk-ant-api03-abc\n", expected: "sk-ant-api03-abc"},
+		{name: "Gateway key", apiKey: "gw-123", expected: "gw-123", warning: "does not start with sk-ant-"},
+		{name: "Inner whitespace", apiKey: "sk-ant-api03 abc", expected: "sk-ant-api03 abc", warning: "contains whitespace"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			client, err := NewClient(
+				WithAPIKey(tc.apiKey),
+				WithSlogLogger(slog.New(slog.NewTextHandler(&buf, nil))),
+			)
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			if client.APIKey != tc.expected {
+				t.Errorf("Expected API key %q, got %q", tc.expected, client.APIKey)
+			}
+			if tc.warning == "" && buf.Len() != 0 {
+				t.Errorf("Expected no warning, got %q", buf.String())
+			}
+			if tc.warning != "" && !strings.Contains(buf.String(), tc.warning) {
+				t.Errorf("Expected a warning containing %q, got %q", tc.warning, buf.String())
+			}
+			if strings.Contains(buf.String(), tc.expected) {
+				t.Errorf("Expected the warning not to contain the API key, got %q", buf.String())
+			}
+		})
+	}
+
+	t.Setenv(envAPIKey, "")
+	if _, err := NewClient(WithAPIKey(" \n")); err == nil {
+		t.Error("Expected an error for a whitespace-only API key")
+	}
+}
+
+func TestWithHeaderReservedHeaders(t *testing.T) {
+	var beta string
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		beta = r.Header.Get("anthropic-beta")
+		return testResponse{value: Message{ID: "msg_123", Role: "assistant"}}
+	})
+	defer server.Close()
+
+	client, err := NewClient(
+		WithAPIKey("test-key"),
+		WithBaseURL(server.URL),
+		WithBetaFeatures(BetaPDFs),
+		WithHeader("anthropic-beta", "gateway-beta-1, "+BetaPDFs),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if _, err := client.Messages().Create(context.Background(), &MessageParams{Model: string(ModelSonnet35), MaxTokens: 8192}); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	expected := BetaPDFs + "," + BetaMaxTokens35Sonnet + ",gateway-beta-1"
+	if beta != expected {
+		t.Errorf("Expected anthropic-beta %q, got %q", expected, beta)
+	}
+
+	testCases := []struct {
+		name string
+		key  string
+	}{
+		{name: "API key", key: "x-api-key"},
+		{name: "API version", key: "Anthropic-Version"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewClient(WithAPIKey("test-key"), WithHeader(tc.key, "value")); err == nil {
+				t.Errorf("Expected an error for header %s, got none", tc.key)
+			}
+		})
+	}
+}
+
+func TestGetModelID(t *testing.T) {
+	testCases := map[string]ModelID{
+		"HAIKU":     ModelHaiku,
+		"SONNET":    ModelSonnet,
+		"SONNET_35": ModelSonnet35,
+		"SONNET_37": ModelSonnet37,
+		"OPUS":      ModelOpus,
+	}
+	for name, expected := range testCases {
+		model, ok := GetModelID(name)
+		if !ok || model != expected {
+			t.Errorf("Expected %s to map to %s, got %s (ok=%v)", name, expected, model, ok)
+		}
+	}
+	if _, ok := GetModelID("UNKNOWN"); ok {
+		t.Errorf("Expected an unknown name not to map to a model")
+	}
+}
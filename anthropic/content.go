@@ -0,0 +1,32 @@
+package anthropic
+
+import "encoding/json"
+
+// TextContent creates a text content block.
+func TextContent(text string) ContentBlock {
+	return ContentBlock{Type: "text", Text: text}
+}
+
+// ThinkingContent creates a thinking content block. Thinking blocks sent back to the
+// API must carry the signature they were returned with.
+func ThinkingContent(thinking, signature string) ContentBlock {
+	return ContentBlock{Type: "thinking", Thinking: thinking, Signature: signature}
+}
+
+// ToolUseContent creates a tool_use content block calling the named tool with the
+// given JSON input.
+func ToolUseContent(id, name string, input json.RawMessage) ContentBlock {
+	return ContentBlock{
+		Type:     "tool_use",
+		ToolCall: &ToolCall{ID: id, Type: "tool_use", Name: name, Input: input},
+	}
+}
+
+// ToolResultContent creates a tool_result content block answering the tool_use block
+// with the given id. Set isError if output describes a failure of the tool.
+func ToolResultContent(id, output string, isError bool) ContentBlock {
+	return ContentBlock{
+		Type:       "tool_result",
+		ToolOutput: &ToolOutput{ToolCallID: id, Output: output, IsError: isError},
+	}
+}
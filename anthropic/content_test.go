@@ -0,0 +1,64 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestContentConstructors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		block    ContentBlock
+		expected ContentBlock
+	}{
+		{
+			name:     "Text",
+			block:    TextContent("Hello"),
+			expected: ContentBlock{Type: "text", Text: "Hello"},
+		},
+		{
+			name:     "Thinking",
+			block:    ThinkingContent("Let me think.", "sig_1"),
+			expected: ContentBlock{Type: "thinking", Thinking: "Let me think.", Signature: "sig_1"},
+		},
+		{
+			name:  "Tool use",
+			block: ToolUseContent("toolu_1", "get_stock_price", json.RawMessage(`{"ticker":"^GSPC"}`)),
+			expected: ContentBlock{
+				Type:     "tool_use",
+				ToolCall: &ToolCall{ID: "toolu_1", Type: "tool_use", Name: "get_stock_price", Input: json.RawMessage(`{"ticker":"^GSPC"}`)},
+			},
+		},
+		{
+			name:     "Tool result",
+			block:    ToolResultContent("toolu_1", "quote service unavailable", true),
+			expected: ContentBlock{Type: "tool_result", ToolOutput: &ToolOutput{ToolCallID: "toolu_1", Output: "quote service unavailable", IsError: true}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tc.block, tc.expected) {
+				t.Errorf("Expected %+v, got %+v", tc.expected, tc.block)
+			}
+		})
+	}
+}
+
+func TestContentConstructorsValidConversation(t *testing.T) {
+	params := &MessageParams{Messages: []MessageParam{
+		{Role: "user", Content: []ContentBlock{TextContent("What's the S&P 500 at?")}},
+		{Role: "assistant", Content: []ContentBlock{
+			ThinkingContent("I should look it up.", "sig_1"),
+			ToolUseContent("toolu_1", "get_stock_price", json.RawMessage(`{"ticker":"^GSPC"}`)),
+		}},
+		{Role: "user", Content: []ContentBlock{ToolResultContent("toolu_1", "4,000.00", false)}},
+	}}
+	if err := params.Validate(); err != nil {
+		t.Errorf("Expected the conversation to be valid, got %v", err)
+	}
+	if err := validateToolResultOrdering(params.Messages); err != nil {
+		t.Errorf("Expected the tool results to match the tool calls, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	countTokensEndpoint = "/messages/count_tokens"
+
+	// defaultCountTokensConcurrency bounds the number of concurrent requests made by
+	// CountTokensBatch unless set with WithCountTokensConcurrency.
+	defaultCountTokensConcurrency = 8
+)
+
+// WithCountTokensConcurrency sets the maximum number of count_tokens requests that
+// CountTokensBatch has in flight at once. The default is 8.
+func WithCountTokensConcurrency(n int) ClientOption {
+	return func(c *Client) error {
+		if n < 1 {
+			return fmt.Errorf("count tokens concurrency must be at least 1, got %d", n)
+		}
+		c.countTokensConcurrency = n
+		return nil
+	}
+}
+
+// TokenCount is the result of counting the tokens of a request.
+type TokenCount struct {
+	InputTokens int `json:"input_tokens"`
+}
+
+// countTokensRequest holds the subset of MessageParams accepted by the count_tokens endpoint.
+type countTokensRequest struct {
+	Model      string          `json:"model"`
+	System     interface{}     `json:"system,omitempty"`
+	Messages   []MessageParam  `json:"messages"`
+	Tools      []Tool          `json:"tools,omitempty"`
+	ToolChoice *ToolChoice     `json:"tool_choice,omitempty"`
+	Thinking   *ThinkingConfig `json:"thinking,omitempty"`
+}
+
+// CountTokens counts the input tokens the given params would consume, without creating a message.
+func (s *MessagesService) CountTokens(ctx context.Context, params *MessageParams) (*TokenCount, error) {
+	c := s.client
+	params = c.withCachedSystemPrompt(params)
+	body, err := json.Marshal(countTokensRequest{
+		Model:      params.Model,
+		System:     params.systemPrompt(),
+		Messages:   params.Messages,
+		Tools:      params.Tools,
+		ToolChoice: params.ToolChoice,
+		Thinking:   params.Thinking,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling request body: %w", err)
+	}
+
+	req, err := c.newRequest(ctx, "POST", countTokensEndpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if err := c.applyRequestEditors(ctx, req); err != nil {
+		return nil, err
+	}
+
+	resp, err := c.doRequest(ctx, req, body, params)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var count TokenCount
+	if err := json.NewDecoder(resp.Body).Decode(&count); err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", err)
+	}
+	return &count, nil
+}
+
+// CountTokensBatch counts the input tokens of each of the given params concurrently,
+// with at most eight requests in flight (see WithCountTokensConcurrency), and returns
+// the counts in the same order. Retryable failures are retried as configured with
+// WithMaxRetries; after a rate_limit_error a count waits at least until the rate
+// limit resets. If any count fails, the remaining requests are cancelled and the
+// first error is returned, identifying the failed params by index. A nil element is
+// an error, reported before any request is sent.
+func (s *MessagesService) CountTokensBatch(ctx context.Context, params []*MessageParams) ([]TokenCount, error) {
+	for i, p := range params {
+		if p == nil {
+			return nil, fmt.Errorf("params %d is nil", i)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	counts := make([]TokenCount, len(params))
+	sem := make(chan struct{}, s.client.countTokensConcurrency)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i, p := range params {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(index int, p *MessageParams) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			count, err := s.countTokensWithRetry(ctx, p)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = fmt.Errorf("error counting tokens for params %d: %w", index, err)
+					cancel()
+				})
+				return
+			}
+			counts[index] = *count
+		}(i, p)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// countTokensWithRetry counts the tokens of params, retrying retryable failures. The
+// backoff before a retry is extended to the rate limit reset reported with the error.
+func (s *MessagesService) countTokensWithRetry(ctx context.Context, params *MessageParams) (*TokenCount, error) {
+	c := s.client
+	for attempt := 0; ; attempt++ {
+		count, err := s.CountTokens(ctx, params)
+		if err == nil || !c.shouldRetry(params, err, attempt) {
+			return count, err
+		}
+
+		delay := c.retryDelay(attempt, err)
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			delay = max(delay, apiErr.RateLimit.WaitDuration())
+		}
+		c.log(ctx, LogLevelWarn, "retrying count tokens request", "attempt", attempt+1, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// EstimateCost estimates the cost in US dollars of sending params, assuming the
+// response will contain expectedOutputTokens output tokens. Input tokens are counted
+// via the API. Pass params.MaxTokens as expectedOutputTokens for an upper bound.
+//
+// The count does not tell which tokens will be written to or read from the prompt
+// cache, so all input tokens are priced at the regular input price. Use Pricing.Cost
+// with a response's Usage for the actual cost, including cache tokens.
+func (s *MessagesService) EstimateCost(ctx context.Context, params *MessageParams, expectedOutputTokens int) (float64, error) {
+	pricing, ok := GetPricing(ModelID(params.Model))
+	if !ok {
+		return 0, fmt.Errorf("no pricing available for model %q", params.Model)
+	}
+	if expectedOutputTokens < 0 {
+		return 0, fmt.Errorf("expected output tokens must not be negative, got %d", expectedOutputTokens)
+	}
+
+	count, err := s.CountTokens(ctx, params)
+	if err != nil {
+		return 0, fmt.Errorf("error counting tokens: %w", err)
+	}
+	return pricing.Cost(Usage{InputTokens: count.InputTokens, OutputTokens: expectedOutputTokens}), nil
+}
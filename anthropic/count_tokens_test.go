@@ -0,0 +1,236 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenCountResponse answers count_tokens requests with inputTokens, checking that
+// the request leaves out max_tokens.
+func tokenCountResponse(t *testing.T, inputTokens int) func(r *http.Request) testResponse {
+	return func(r *http.Request) testResponse {
+		if r.URL.Path != countTokensEndpoint {
+			t.Errorf("Expected path %s, got %s", countTokensEndpoint, r.URL.Path)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if _, ok := body["max_tokens"]; ok {
+			t.Errorf("Expected max_tokens to be omitted from count_tokens request")
+		}
+		return testResponse{value: TokenCount{InputTokens: inputTokens}}
+	}
+}
+
+func TestMessagesService_CountTokens(t *testing.T) {
+	server := newTestServer(t, tokenCountResponse(t, 42))
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	count, err := client.Messages().CountTokens(context.Background(), &MessageParams{
+		Model:     string(ModelSonnet),
+		MaxTokens: 1024,
+		Messages: []MessageParam{
+			{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Hello"}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to count tokens: %v", err)
+	}
+	if count.InputTokens != 42 {
+		t.Errorf("Expected 42 input tokens, got %d", count.InputTokens)
+	}
+}
+
+func TestMessagesService_EstimateCost(t *testing.T) {
+	server := newTestServer(t, tokenCountResponse(t, 100000))
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	params := &MessageParams{Model: string(ModelSonnet), MaxTokens: 4096}
+
+	cost, err := client.Messages().EstimateCost(context.Background(), params, 2000)
+	if err != nil {
+		t.Fatalf("Failed to estimate cost: %v", err)
+	}
+	// 100k input tokens at $3/M plus 2k output tokens at $15/M.
+	expected := 0.3 + 0.03
+	if math.Abs(cost-expected) > 1e-9 {
+		t.Errorf("Expected cost %f, got %f", expected, cost)
+	}
+
+	if _, err := client.Messages().EstimateCost(context.Background(), &MessageParams{Model: "unknown-model"}, 10); err == nil {
+		t.Errorf("Expected an error for a model without pricing")
+	}
+}
+
+func TestMessagesService_CountTokensBatch(t *testing.T) {
+	var tracker concurrencyTracker
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		defer tracker.enter()()
+		time.Sleep(10 * time.Millisecond)
+
+		var params MessageParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		text := params.Messages[0].Content[0].Text
+		if text == "fail" {
+			return testResponse{status: http.StatusBadRequest, body: `{"type":"error","error":{"type":"invalid_request_error","message":"bad prompt"}}`}
+		}
+		return testResponse{value: TokenCount{InputTokens: len(text)}}
+	})
+	defer server.Close()
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	var params []*MessageParams
+	for i := 0; i < 20; i++ {
+		params = append(params, &MessageParams{
+			Model:    string(ModelSonnet),
+			Messages: []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: strings.Repeat("x", i+1)}}}},
+		})
+	}
+
+	counts, err := client.Messages().CountTokensBatch(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Failed to count tokens: %v", err)
+	}
+	if len(counts) != len(params) {
+		t.Fatalf("Expected %d counts, got %d", len(params), len(counts))
+	}
+	for i, count := range counts {
+		if count.InputTokens != i+1 {
+			t.Errorf("Expected count %d to be %d, got %d", i, i+1, count.InputTokens)
+		}
+	}
+	if got := tracker.max(); got > defaultCountTokensConcurrency || got < 2 {
+		t.Errorf("Expected between 2 and %d concurrent requests, got %d", defaultCountTokensConcurrency, got)
+	}
+
+	params[13].Messages[0].Content[0].Text = "fail"
+	_, err = client.Messages().CountTokensBatch(context.Background(), params)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !strings.Contains(err.Error(), fmt.Sprintf("params %d", 13)) {
+		t.Errorf("Expected an API error for params 13, got %v", err)
+	}
+}
+
+func TestMessagesService_CountTokensBatchConcurrency(t *testing.T) {
+	var tracker concurrencyTracker
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		defer tracker.enter()()
+		time.Sleep(5 * time.Millisecond)
+		return testResponse{value: TokenCount{InputTokens: 1}}
+	})
+	defer server.Close()
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL), WithCountTokensConcurrency(2))
+
+	params := make([]*MessageParams, 10)
+	for i := range params {
+		params[i] = &MessageParams{Model: string(ModelSonnet), Messages: []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Hi"}}}}}
+	}
+	if _, err := client.Messages().CountTokensBatch(context.Background(), params); err != nil {
+		t.Fatalf("Failed to count tokens: %v", err)
+	}
+	if got := tracker.max(); got > 2 {
+		t.Errorf("Expected at most 2 concurrent requests, got %d", got)
+	}
+
+	if _, err := NewClient(WithAPIKey("test-key"), WithCountTokensConcurrency(0)); err == nil {
+		t.Errorf("Expected an error for zero concurrency, got none")
+	}
+}
+
+func TestMessagesService_CountTokensBatchNilParams(t *testing.T) {
+	var requests atomic.Int32
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		requests.Add(1)
+		return testResponse{value: TokenCount{InputTokens: 1}}
+	})
+	defer server.Close()
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	params := []*MessageParams{{Model: string(ModelSonnet)}, nil}
+	_, err := client.Messages().CountTokensBatch(context.Background(), params)
+	if err == nil || err.Error() != "params 1 is nil" {
+		t.Fatalf("Expected an error for the nil params, got %v", err)
+	}
+	if got := requests.Load(); got != 0 {
+		t.Errorf("Expected no requests to be sent, got %d", got)
+	}
+}
+
+func TestMessagesService_CountTokensBatchRateLimitRetry(t *testing.T) {
+	const resetAfter = 50 * time.Millisecond
+	var calls atomic.Int32
+	var limitedAt, retriedAt atomic.Int64
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		if calls.Add(1) == 1 {
+			limitedAt.Store(time.Now().UnixNano())
+			header := http.Header{}
+			header.Set(headerRequestsLimit, "50")
+			header.Set(headerRequestsRemaining, "0")
+			header.Set(headerRequestsReset, time.Now().Add(resetAfter).UTC().Format(time.RFC3339Nano))
+			return testResponse{
+				status: http.StatusTooManyRequests,
+				header: header,
+				body:   `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`,
+			}
+		}
+		retriedAt.Store(time.Now().UnixNano())
+		return testResponse{value: TokenCount{InputTokens: 7}}
+	})
+	defer server.Close()
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL), WithMaxRetries(1))
+	client.retryBaseDelay = time.Millisecond
+
+	counts, err := client.Messages().CountTokensBatch(context.Background(), []*MessageParams{{Model: string(ModelSonnet), Messages: []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Hi"}}}}}})
+	if err != nil {
+		t.Fatalf("Expected the rate limited count to be retried, got %v", err)
+	}
+	if len(counts) != 1 || counts[0].InputTokens != 7 {
+		t.Errorf("Expected counts [{7}], got %v", counts)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Expected 2 requests, got %d", got)
+	}
+	// The reset header has millisecond-level precision at best, so allow some slack.
+	if waited := time.Duration(retriedAt.Load() - limitedAt.Load()); waited < resetAfter-10*time.Millisecond {
+		t.Errorf("Expected the retry to wait for the rate limit reset of %v, waited %v", resetAfter, waited)
+	}
+}
+
+func TestPricingCost(t *testing.T) {
+	pricing, _ := GetPricing(ModelSonnet35)
+	testCases := []struct {
+		name     string
+		usage    Usage
+		expected float64
+	}{
+		{name: "Input and output", usage: Usage{InputTokens: 1000000, OutputTokens: 1000000}, expected: 18},
+		{name: "Cache write", usage: Usage{CacheCreationInputTokens: 1000000}, expected: 3.75},
+		{name: "Cache read", usage: Usage{CacheReadInputTokens: 1000000}, expected: 0.3},
+		{
+			name:     "All token kinds",
+			usage:    Usage{InputTokens: 1000, OutputTokens: 500, CacheCreationInputTokens: 2000, CacheReadInputTokens: 10000},
+			expected: 0.003 + 0.0075 + 0.0075 + 0.003,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if cost := pricing.Cost(tc.usage); math.Abs(cost-tc.expected) > 1e-9 {
+				t.Errorf("Expected cost %f, got %f", tc.expected, cost)
+			}
+		})
+	}
+}
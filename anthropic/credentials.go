@@ -0,0 +1,71 @@
+package anthropic
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultAPIKeyCacheTTL is how long a key returned by an API key function is reused.
+const defaultAPIKeyCacheTTL = time.Minute
+
+// WithAPIKeyFunc sets a function that supplies the API key, e.g. from an OS keychain,
+// a secrets vault, or a credential helper. The function is called lazily when a
+// request is sent and its result is cached for a minute, so rotated keys take effect
+// without rebuilding the client. The cache is also cleared when the API rejects the
+// key with a 401 response. It takes precedence over WithAPIKey.
+func WithAPIKeyFunc(fn func() (string, error)) ClientOption {
+	return func(c *Client) error {
+		if fn == nil {
+			return fmt.Errorf("API key function must not be nil")
+		}
+		c.apiKeyFunc = &apiKeyCache{fn: fn, ttl: defaultAPIKeyCacheTTL, now: time.Now}
+		return nil
+	}
+}
+
+// apiKeyCache caches the result of an API key function for a limited time.
+type apiKeyCache struct {
+	fn  func() (string, error)
+	ttl time.Duration
+	now func() time.Time
+
+	mu        sync.Mutex
+	key       string
+	fetchedAt time.Time
+}
+
+// get returns the cached key, calling the key function if the cache is empty or expired.
+func (c *apiKeyCache) get() (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.key != "" && c.now().Sub(c.fetchedAt) < c.ttl {
+		return c.key, nil
+	}
+	key, err := c.fn()
+	if err != nil {
+		return "", fmt.Errorf("error fetching API key: %w", err)
+	}
+	if key == "" {
+		return "", fmt.Errorf("API key function returned an empty key")
+	}
+	c.key = key
+	c.fetchedAt = c.now()
+	return key, nil
+}
+
+// invalidate clears the cached key so the next request fetches a fresh one.
+func (c *apiKeyCache) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.key = ""
+}
+
+// resolveAPIKey returns the API key to use for the next request.
+func (c *Client) resolveAPIKey() (string, error) {
+	if c.apiKeyFunc != nil {
+		return c.apiKeyFunc.get()
+	}
+	return c.APIKey, nil
+}
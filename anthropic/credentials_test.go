@@ -0,0 +1,87 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithAPIKeyFunc(t *testing.T) {
+	var seenKeys []string
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		key := r.Header.Get("X-API-Key")
+		seenKeys = append(seenKeys, key)
+		if key == "revoked-key" {
+			return testResponse{status: http.StatusUnauthorized, body: `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`}
+		}
+		return testResponse{value: Message{ID: "msg_123", Role: "assistant"}}
+	})
+	defer server.Close()
+
+	calls := 0
+	keys := []string{"key-1", "revoked-key", "key-2"}
+	client, err := NewClient(
+		WithBaseURL(server.URL),
+		WithAPIKeyFunc(func() (string, error) {
+			key := keys[calls]
+			calls++
+			return key, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Expected the key to be fetched lazily, got %d calls at construction", calls)
+	}
+
+	now := time.Now()
+	client.apiKeyFunc.now = func() time.Time { return now }
+	params := &MessageParams{Model: string(ModelSonnet)}
+
+	// The first two requests share the cached key.
+	for i := 0; i < 2; i++ {
+		if _, err := client.Messages().Create(context.Background(), params); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected the key to be cached, got %d calls", calls)
+	}
+
+	// After the TTL the key is refreshed; a 401 invalidates it immediately.
+	now = now.Add(defaultAPIKeyCacheTTL)
+	if _, err := client.Messages().Create(context.Background(), params); err == nil {
+		t.Fatal("Expected an authentication error for the revoked key")
+	}
+	if _, err := client.Messages().Create(context.Background(), params); err != nil {
+		t.Fatalf("Expected the rotated key to be used, got %v", err)
+	}
+
+	expected := []string{"key-1", "key-1", "revoked-key", "key-2"}
+	if len(seenKeys) != len(expected) {
+		t.Fatalf("Expected keys %v, got %v", expected, seenKeys)
+	}
+	for i := range expected {
+		if seenKeys[i] != expected[i] {
+			t.Errorf("Expected keys %v, got %v", expected, seenKeys)
+			break
+		}
+	}
+}
+
+func TestWithAPIKeyFuncError(t *testing.T) {
+	client, err := NewClient(WithAPIKeyFunc(func() (string, error) {
+		return "", errors.New("keychain locked")
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	_, err = client.Messages().Create(context.Background(), &MessageParams{Model: string(ModelSonnet)})
+	if err == nil || !strings.Contains(err.Error(), "keychain locked") {
+		t.Fatalf("Expected key function error, got %v", err)
+	}
+}
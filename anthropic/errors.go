@@ -0,0 +1,207 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Error types returned by the API.
+const (
+	ErrorTypeInvalidRequest = "invalid_request_error"
+	ErrorTypeAuthentication = "authentication_error"
+	ErrorTypePermission     = "permission_error"
+	ErrorTypeNotFound       = "not_found_error"
+	ErrorTypeRateLimit      = "rate_limit_error"
+	ErrorTypeAPI            = "api_error"
+	ErrorTypeOverloaded     = "overloaded_error"
+)
+
+// StatusOverloaded is the non-standard HTTP status used by the API when it is overloaded.
+const StatusOverloaded = 529
+
+// overloadedBackoffFactor scales the retry base delay for overloaded errors, since
+// capacity issues take longer to clear than rate limits.
+const overloadedBackoffFactor = 4
+
+// ErrOverloaded matches, via errors.Is, any *APIError caused by the API being overloaded.
+// The *APIError itself can still be retrieved with errors.As.
+var ErrOverloaded = errors.New("API is overloaded")
+
+// ErrIncompleteToolInput matches, via errors.Is, any *IncompleteToolInputError.
+var ErrIncompleteToolInput = errors.New("incomplete tool input")
+
+// IncompleteToolInputError is returned when a stream ends while the JSON input of a
+// tool call is still incomplete, typically because the response hit max_tokens.
+type IncompleteToolInputError struct {
+	ToolUseID   string
+	ToolName    string
+	PartialJSON string
+}
+
+// Error implements the error interface.
+func (e *IncompleteToolInputError) Error() string {
+	return fmt.Sprintf("incomplete input for tool %q (%s): %q", e.ToolName, e.ToolUseID, e.PartialJSON)
+}
+
+// Is reports whether target is ErrIncompleteToolInput.
+func (e *IncompleteToolInputError) Is(target error) bool {
+	return target == ErrIncompleteToolInput
+}
+
+// ErrClientTimeout matches, via errors.Is, errors caused by the HTTP client's timeout
+// set with WithTimeout, as opposed to the deadline of the call's context.
+var ErrClientTimeout = errors.New("client timeout exceeded")
+
+// IsTimeout reports whether err was caused by a timeout: either the client timeout set
+// with WithTimeout or the deadline of the call's context, in both streaming and
+// non-streaming calls. Use errors.Is(err, ErrClientTimeout) to tell the former from
+// the latter.
+func IsTimeout(err error) bool {
+	if errors.Is(err, ErrClientTimeout) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsCanceled reports whether err was caused by the cancellation of the call's context.
+// It is false for timeouts.
+func IsCanceled(err error) bool {
+	return errors.Is(err, context.Canceled) && !IsTimeout(err)
+}
+
+// IsRetryableStreamError reports whether a streaming call failed partway for a
+// transient reason, such as a dropped connection, an unexpected EOF or a network
+// timeout, so that resending the request may succeed.
+// Streaming requests are not retried by the client, as their output may already have
+// been consumed; this lets callers implement their own stream retries.
+//
+// It is false for the cancellation or deadline of the call's context, for errors
+// returned by the API, for errors returned by the request's callbacks, and for
+// network errors that would recur, such as an unknown host or a certificate that
+// fails verification.
+func IsRetryableStreamError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return false
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, ErrClientTimeout) {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "read"
+}
+
+// classifyTransportError makes a failure to send a request or read its response
+// match ErrClientTimeout or the context's error, whichever caused it. The transport
+// reports both client timeouts and context deadlines as timeouts, and may report a
+// cancelled read with an error that does not match context.Canceled.
+func classifyTransportError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		if errors.Is(err, ctxErr) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ctxErr, err)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("%w: %w", ErrClientTimeout, err)
+	}
+	return err
+}
+
+// APIError represents a non-200 response returned by the API.
+type APIError struct {
+	StatusCode int
+	Type       string
+	Message    string
+	Body       string
+	// RateLimit is the rate limit state reported with the error response, e.g. to
+	// wait with RateLimit.WaitDuration after a rate_limit_error.
+	RateLimit RateLimitInfo
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// IsOverloaded reports whether the error was caused by the API being overloaded.
+func (e *APIError) IsOverloaded() bool {
+	return e.StatusCode == StatusOverloaded || e.Type == ErrorTypeOverloaded
+}
+
+// Is reports whether the error matches target, making errors.Is(err, ErrOverloaded)
+// true for overloaded errors.
+func (e *APIError) Is(target error) bool {
+	return target == ErrOverloaded && e.IsOverloaded()
+}
+
+// RecommendedBaseDelay returns the suggested base delay for backing off before
+// retrying, given the base delay used for other retryable errors, such as the one
+// configured on the client. Overloaded errors recommend a longer delay.
+func (e *APIError) RecommendedBaseDelay(base time.Duration) time.Duration {
+	if e.IsOverloaded() {
+		return base * overloadedBackoffFactor
+	}
+	return base
+}
+
+// errorResponse is the JSON envelope of an API error body.
+type errorResponse struct {
+	Type  string `json:"type"`
+	Error Error  `json:"error"`
+}
+
+// newAPIError builds an APIError from a response status and body.
+// The body is parsed on a best-effort basis; a non-JSON body is kept verbatim.
+func newAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		Body:       string(body),
+	}
+	var envelope errorResponse
+	if err := json.Unmarshal(body, &envelope); err == nil {
+		apiErr.Type = envelope.Error.Type
+		apiErr.Message = envelope.Error.Message
+	}
+	// A 529 may come from a CDN or proxy in front of the API, with an HTML or
+	// otherwise non-standard body; it still means the API is overloaded.
+	if apiErr.Type == "" && statusCode == StatusOverloaded {
+		apiErr.Type = ErrorTypeOverloaded
+		apiErr.Message = ErrOverloaded.Error()
+	}
+	return apiErr
+}
+
+// isModelUnavailable reports whether err indicates the requested model cannot serve
+// the request right now, either because it is overloaded or because it does not exist.
+func isModelUnavailable(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.IsOverloaded() {
+		return true
+	}
+	return (apiErr.StatusCode == http.StatusNotFound || apiErr.Type == ErrorTypeNotFound) &&
+		strings.Contains(strings.ToLower(apiErr.Message), "model")
+}
@@ -0,0 +1,297 @@
+package anthropic
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNewAPIError(t *testing.T) {
+	testCases := []struct {
+		name            string
+		status          int
+		body            string
+		expectedType    string
+		expectedMessage string
+	}{
+		{
+			name:            "JSON error body",
+			status:          http.StatusTooManyRequests,
+			body:            `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`,
+			expectedType:    ErrorTypeRateLimit,
+			expectedMessage: "slow down",
+		},
+		{
+			name:   "Non-JSON error body",
+			status: http.StatusBadGateway,
+			body:   "<html>bad gateway</html>",
+		},
+		{
+			name:            "Non-JSON overloaded body",
+			status:          StatusOverloaded,
+			body:            "<html><body>Site overloaded</body></html>",
+			expectedType:    ErrorTypeOverloaded,
+			expectedMessage: "API is overloaded",
+		},
+		{
+			name:            "Non-standard JSON overloaded body",
+			status:          StatusOverloaded,
+			body:            `{"message":"overloaded"}`,
+			expectedType:    ErrorTypeOverloaded,
+			expectedMessage: "API is overloaded",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			apiErr := newAPIError(tc.status, []byte(tc.body))
+			if apiErr.StatusCode != tc.status {
+				t.Errorf("Expected status %d, got %d", tc.status, apiErr.StatusCode)
+			}
+			if apiErr.Type != tc.expectedType {
+				t.Errorf("Expected type %q, got %q", tc.expectedType, apiErr.Type)
+			}
+			if apiErr.Message != tc.expectedMessage {
+				t.Errorf("Expected message %q, got %q", tc.expectedMessage, apiErr.Message)
+			}
+			if apiErr.Body != tc.body {
+				t.Errorf("Expected raw body to be kept, got %q", apiErr.Body)
+			}
+		})
+	}
+}
+
+func TestIsModelUnavailable(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "Overloaded status", err: &APIError{StatusCode: StatusOverloaded}, expected: true},
+		{name: "Overloaded type", err: &APIError{StatusCode: http.StatusServiceUnavailable, Type: ErrorTypeOverloaded}, expected: true},
+		{name: "Unknown model", err: &APIError{StatusCode: http.StatusNotFound, Type: ErrorTypeNotFound, Message: "model: claude-x"}, expected: true},
+		{name: "Rate limited", err: &APIError{StatusCode: http.StatusTooManyRequests, Type: ErrorTypeRateLimit}, expected: false},
+		{name: "Not an API error", err: http.ErrHandlerTimeout, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isModelUnavailable(tc.err); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestOverloadedError(t *testing.T) {
+	body := `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`
+	server := newTestServer(t, respondWith(testResponse{status: StatusOverloaded, body: body}))
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	_, err := client.Messages().Create(context.Background(), &MessageParams{Model: string(ModelSonnet)})
+
+	if !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("Expected ErrOverloaded, got %v", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected *APIError, got %T", err)
+	}
+	if apiErr.Type != ErrorTypeOverloaded || apiErr.Message != "Overloaded" {
+		t.Errorf("Unexpected parsed error: %+v", apiErr)
+	}
+	if !apiErr.IsRetryable() {
+		t.Errorf("Expected overloaded errors to be retryable")
+	}
+	if apiErr.RecommendedBaseDelay(time.Second) <= (&APIError{StatusCode: http.StatusTooManyRequests}).RecommendedBaseDelay(time.Second) {
+		t.Errorf("Expected overloaded errors to recommend a longer delay than rate limits")
+	}
+	if delay := (&APIError{StatusCode: http.StatusTooManyRequests}).RecommendedBaseDelay(time.Second); delay != time.Second {
+		t.Errorf("Expected rate limits to recommend the given base delay %v, got %v", time.Second, delay)
+	}
+
+	rateLimited := &APIError{StatusCode: http.StatusTooManyRequests, Type: ErrorTypeRateLimit}
+	if errors.Is(rateLimited, ErrOverloaded) {
+		t.Errorf("Expected rate limit errors not to match ErrOverloaded")
+	}
+}
+
+func TestOverloadedErrorHTMLBody(t *testing.T) {
+	body := "<html><head><title>529</title></head><body>Overloaded, try again later.</body></html>"
+	server := newTestServer(t, respondWith(testResponse{status: StatusOverloaded, header: http.Header{"Content-Type": {"text/html"}}, body: body}))
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	_, err := client.Messages().Create(context.Background(), &MessageParams{Model: string(ModelSonnet)})
+
+	if !errors.Is(err, ErrOverloaded) {
+		t.Fatalf("Expected ErrOverloaded, got %v", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected *APIError, got %T", err)
+	}
+	if apiErr.Type != ErrorTypeOverloaded || apiErr.Body != body {
+		t.Errorf("Expected an overloaded error with the raw body attached, got %+v", apiErr)
+	}
+	if !apiErr.IsRetryable() {
+		t.Errorf("Expected overloaded errors to be retryable")
+	}
+}
+
+func TestIsTimeoutIsCanceled(t *testing.T) {
+	done := make(chan struct{})
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		if r.URL.Query().Get("stream") != "" {
+			// Stall mid-stream, so that the call fails while reading the body.
+			return testResponse{events: helloStreamEvents[:1], stall: done}
+		}
+		return testResponse{stall: done}
+	})
+	defer server.Close()
+	defer close(done)
+
+	testCases := []struct {
+		name          string
+		clientTimeout time.Duration
+		ctxTimeout    time.Duration
+		cancelAfter   time.Duration
+		isClient      bool
+		isTimeout     bool
+		isCanceled    bool
+	}{
+		{name: "Client timeout", clientTimeout: 50 * time.Millisecond, isClient: true, isTimeout: true},
+		{name: "Context deadline", ctxTimeout: 50 * time.Millisecond, isTimeout: true},
+		{name: "Context cancellation", cancelAfter: 50 * time.Millisecond, isCanceled: true},
+	}
+
+	for _, tc := range testCases {
+		for _, streaming := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s streaming=%v", tc.name, streaming), func(t *testing.T) {
+				opts := []ClientOption{WithAPIKey("test-key"), WithBaseURL(server.URL)}
+				if tc.clientTimeout > 0 {
+					opts = append(opts, WithTimeout(tc.clientTimeout))
+				}
+				if streaming {
+					opts = append(opts, WithRequestEditor(func(ctx context.Context, req *http.Request) error {
+						req.URL.RawQuery = "stream=1"
+						return nil
+					}))
+				}
+				client, _ := NewClient(opts...)
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				if tc.ctxTimeout > 0 {
+					ctx, cancel = context.WithTimeout(ctx, tc.ctxTimeout)
+					defer cancel()
+				}
+				if tc.cancelAfter > 0 {
+					time.AfterFunc(tc.cancelAfter, cancel)
+				}
+
+				params := &MessageParams{Model: string(ModelSonnet)}
+				if streaming {
+					params.StreamFunc = func(ctx context.Context, chunk []byte) error { return nil }
+				}
+				_, err := client.Messages().Create(ctx, params)
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				if got := errors.Is(err, ErrClientTimeout); got != tc.isClient {
+					t.Errorf("Expected errors.Is(err, ErrClientTimeout) to be %v, got %v for %v", tc.isClient, got, err)
+				}
+				if got := IsTimeout(err); got != tc.isTimeout {
+					t.Errorf("Expected IsTimeout to be %v, got %v for %v", tc.isTimeout, got, err)
+				}
+				if got := IsCanceled(err); got != tc.isCanceled {
+					t.Errorf("Expected IsCanceled to be %v, got %v for %v", tc.isCanceled, got, err)
+				}
+			})
+		}
+	}
+
+	if IsTimeout(errors.New("boom")) || IsCanceled(errors.New("boom")) {
+		t.Error("Expected an unrelated error to be neither a timeout nor a cancellation")
+	}
+}
+
+func TestIsRetryableStreamError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "Nil", err: nil, expected: false},
+		{name: "Unexpected EOF", err: fmt.Errorf("issue scanning response: %w", io.ErrUnexpectedEOF), expected: true},
+		{name: "Connection reset", err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, expected: true},
+		{name: "Client timeout", err: fmt.Errorf("%w: %w", ErrClientTimeout, io.ErrUnexpectedEOF), expected: true},
+		{name: "Context cancellation", err: fmt.Errorf("%w: %w", context.Canceled, io.ErrUnexpectedEOF), expected: false},
+		{name: "Context deadline", err: fmt.Errorf("issue scanning response: %w", context.DeadlineExceeded), expected: false},
+		{name: "API error", err: &APIError{StatusCode: http.StatusInternalServerError, Type: ErrorTypeAPI}, expected: false},
+		{name: "Callback error", err: errors.New("stream func failed"), expected: false},
+		{name: "Read error", err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("use of closed network connection")}, expected: true},
+		{name: "Network timeout", err: &url.Error{Op: "Post", URL: "https://api.anthropic.com/v1/messages", Err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}}, expected: true},
+		{
+			name:     "Unknown host",
+			err:      &url.Error{Op: "Post", URL: "https://api.anthropic.invalid/v1/messages", Err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "api.anthropic.invalid", IsNotFound: true}}},
+			expected: false,
+		},
+		{
+			name:     "Certificate verification",
+			err:      &url.Error{Op: "Post", URL: "https://api.anthropic.com/v1/messages", Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}},
+			expected: false,
+		},
+		{name: "Unsupported scheme", err: &url.Error{Op: "Post", URL: "ftp://api.anthropic.com", Err: errors.New(`unsupported protocol scheme "ftp"`)}, expected: false},
+		{name: "Incomplete tool input", err: &IncompleteToolInputError{ToolUseID: "toolu_1"}, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsRetryableStreamError(tc.err); got != tc.expected {
+				t.Errorf("Expected IsRetryableStreamError(%v) to be %v, got %v", tc.err, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestIsRetryableStreamErrorDroppedConnection(t *testing.T) {
+	// The connection is hijacked to send a short body, which newTestServer cannot do.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Failed to hijack connection: %v", err)
+			return
+		}
+		defer conn.Close()
+		// Promise more body than is sent, then drop the connection mid-stream.
+		body := "data: " + helloStreamEvents[0] + "\n\ndata: " + helloStreamEvents[1] + "\n\n"
+		fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Type: text/event-stream\r\nContent-Length: %d\r\n\r\n%s", len(body)+1000, body)
+		_ = buf.Flush()
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	params := &MessageParams{
+		Model:      string(ModelSonnet),
+		StreamFunc: func(ctx context.Context, chunk []byte) error { return nil },
+	}
+	_, err := client.Messages().Create(context.Background(), params)
+	if err == nil {
+		t.Fatal("Expected an error for a dropped connection")
+	}
+	if !IsRetryableStreamError(err) {
+		t.Errorf("Expected a dropped connection to be retryable, got %v", err)
+	}
+}
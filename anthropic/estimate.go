@@ -0,0 +1,118 @@
+package anthropic
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/gif"  // register the GIF decoder for image.DecodeConfig
+	_ "image/jpeg" // register the JPEG decoder for image.DecodeConfig
+	_ "image/png"  // register the PNG decoder for image.DecodeConfig
+	"unicode/utf8"
+)
+
+const (
+	// imagePixelsPerToken is the API's rule of thumb for image cost: an image
+	// consumes about width*height/750 tokens.
+	imagePixelsPerToken = 750
+	// maxImageTokens is the cost of the largest image the API processes without
+	// downscaling it; larger images are resized to about this many tokens.
+	maxImageTokens = 1600
+	// charsPerToken approximates the number of characters per text token.
+	charsPerToken = 4
+)
+
+// EstimateImageTokens estimates the tokens an image of the given dimensions in pixels
+// consumes, using the width*height/750 heuristic, rounded up. Images larger than the
+// API's size limits are downscaled by the API, so the estimate is capped at the cost
+// of the largest image the API accepts as is.
+func EstimateImageTokens(width, height int) int {
+	if width <= 0 || height <= 0 {
+		return 0
+	}
+	tokens := (width*height + imagePixelsPerToken - 1) / imagePixelsPerToken
+	if tokens > maxImageTokens {
+		return maxImageTokens
+	}
+	return tokens
+}
+
+// EstimateTokens estimates the input tokens the params would consume, without calling
+// the API. Text, including the system prompt, tool inputs and tool results, is
+// estimated at about four characters per token. Base64 image blocks are estimated
+// with EstimateImageTokens from the dimensions decoded from their data; images whose
+// dimensions are unknown, such as URL or file sources, count as the largest image.
+//
+// The estimate is meant for budgeting; use CountTokens for an exact count.
+func (p *MessageParams) EstimateTokens() int {
+	chars := utf8.RuneCountInString(p.System)
+	for _, block := range p.SystemBlocks {
+		chars += utf8.RuneCountInString(block.Text)
+	}
+
+	tokens := 0
+	for _, message := range p.Messages {
+		for _, block := range message.Content {
+			chars += utf8.RuneCountInString(block.Text) + utf8.RuneCountInString(block.Thinking)
+			if block.ToolCall != nil {
+				chars += utf8.RuneCount(block.ToolCall.Input)
+			}
+			if block.ToolOutput != nil {
+				chars += utf8.RuneCountInString(block.ToolOutput.Output)
+			}
+			if block.Type == "image" {
+				tokens += estimateImageBlockTokens(block.Source)
+			}
+		}
+	}
+	return tokens + (chars+charsPerToken-1)/charsPerToken
+}
+
+// estimateImageBlockTokens estimates the tokens of an image source, falling back to
+// maxImageTokens when its dimensions cannot be determined.
+func estimateImageBlockTokens(source *Image) int {
+	if source == nil || source.Type != "base64" {
+		return maxImageTokens
+	}
+	data, err := base64.StdEncoding.DecodeString(source.Data)
+	if err != nil {
+		return maxImageTokens
+	}
+	width, height, err := imageDimensions(data)
+	if err != nil {
+		return maxImageTokens
+	}
+	return EstimateImageTokens(width, height)
+}
+
+// imageDimensions decodes the width and height of a PNG, JPEG, GIF or WebP image
+// from its header, without decoding the pixel data.
+func imageDimensions(data []byte) (int, int, error) {
+	if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		return config.Width, config.Height, nil
+	}
+	return webpDimensions(data)
+}
+
+// webpDimensions reads the canvas size from the header of a lossy (VP8), lossless
+// (VP8L) or extended (VP8X) WebP image.
+func webpDimensions(data []byte) (int, int, error) {
+	if len(data) < 30 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return 0, 0, fmt.Errorf("not a supported image")
+	}
+	switch string(data[12:16]) {
+	case "VP8 ":
+		width := binary.LittleEndian.Uint16(data[26:28]) & 0x3fff
+		height := binary.LittleEndian.Uint16(data[28:30]) & 0x3fff
+		return int(width), int(height), nil
+	case "VP8L":
+		bits := binary.LittleEndian.Uint32(data[21:25])
+		return int(bits&0x3fff) + 1, int(bits>>14&0x3fff) + 1, nil
+	case "VP8X":
+		width := uint32(data[24]) | uint32(data[25])<<8 | uint32(data[26])<<16
+		height := uint32(data[27]) | uint32(data[28])<<8 | uint32(data[29])<<16
+		return int(width) + 1, int(height) + 1, nil
+	}
+	return 0, 0, fmt.Errorf("unsupported WebP format %q", data[12:16])
+}
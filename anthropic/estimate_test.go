@@ -0,0 +1,106 @@
+package anthropic
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestEstimateImageTokens(t *testing.T) {
+	testCases := []struct {
+		name     string
+		width    int
+		height   int
+		expected int
+	}{
+		{name: "Exact multiple", width: 750, height: 10, expected: 10},
+		{name: "Rounded up", width: 200, height: 200, expected: 54},
+		{name: "Capped", width: 4000, height: 3000, expected: maxImageTokens},
+		{name: "Empty", width: 0, height: 100, expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := EstimateImageTokens(tc.width, tc.height); got != tc.expected {
+				t.Errorf("Expected %d tokens, got %d", tc.expected, got)
+			}
+		})
+	}
+}
+
+func encodeTestPNG(t *testing.T, width, height int) string {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("Failed to encode PNG: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestImageDimensionsWebP(t *testing.T) {
+	data := make([]byte, 30)
+	copy(data, "RIFF\x00\x00\x00\x00WEBPVP8X")
+	// Canvas width and height minus one, as 24-bit little-endian values.
+	copy(data[24:], []byte{0x1f, 0x03, 0x00, 0x57, 0x02, 0x00})
+
+	width, height, err := imageDimensions(data)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if width != 800 || height != 600 {
+		t.Errorf("Expected 800x600, got %dx%d", width, height)
+	}
+
+	if _, _, err := imageDimensions([]byte("not an image")); err == nil {
+		t.Error("Expected an error for data that is not an image")
+	}
+}
+
+func TestMessageParamsEstimateTokens(t *testing.T) {
+	testCases := []struct {
+		name     string
+		params   *MessageParams
+		expected int
+	}{
+		{
+			name: "Text only",
+			params: &MessageParams{
+				System:   "Be brief.",
+				Messages: []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Hello, Claude!"}}}},
+			},
+			expected: 6,
+		},
+		{
+			name: "Base64 image",
+			params: &MessageParams{Messages: []MessageParam{{Role: "user", Content: []ContentBlock{
+				{Type: "image", Source: &Image{Type: "base64", MediaType: MediaTypePNG, Data: encodeTestPNG(t, 300, 250)}},
+				{Type: "text", Text: "Describe"},
+			}}}},
+			expected: 100 + 2,
+		},
+		{
+			name: "Undecodable image",
+			params: &MessageParams{Messages: []MessageParam{{Role: "user", Content: []ContentBlock{
+				{Type: "image", Source: &Image{Type: "base64", MediaType: MediaTypePNG, Data: "aGVsbG8="}},
+			}}}},
+			expected: maxImageTokens,
+		},
+		{
+			name: "Tool use and result",
+			params: &MessageParams{Messages: []MessageParam{
+				mustAssistantToolUse(t, "toolu_1", "get_stock_price", stockPriceArgs{Ticker: "^GSPC"}),
+				NewToolResultMessage("toolu_1", "4,000.00"),
+			}},
+			expected: 9,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.params.EstimateTokens(); got != tc.expected {
+				t.Errorf("Expected %d tokens, got %d", tc.expected, got)
+			}
+		})
+	}
+}
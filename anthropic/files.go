@@ -0,0 +1,39 @@
+package anthropic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+const filesEndpoint = "/files"
+
+// Download retrieves the content of a file, such as one produced by a server-side tool
+// and referenced by a {"type":"file","file_id":"..."} block in a tool result.
+// The caller is responsible for closing the returned reader.
+func (s *FilesService) Download(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	if fileID == "" {
+		return nil, fmt.Errorf("file ID is required")
+	}
+	c := s.client
+	endpoint := filesEndpoint + "/" + url.PathEscape(fileID) + "/content"
+
+	req, err := c.newRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("anthropic-beta", strings.Join(mergeBetas(c.betaFeatures, []string{BetaFilesAPI}), ","))
+	if err := c.applyRequestEditors(ctx, req); err != nil {
+		return nil, err
+	}
+
+	c.log(ctx, LogLevelDebug, "downloading file", "file_id", fileID)
+
+	resp, err := c.doRequest(ctx, req, nil, &MessageParams{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
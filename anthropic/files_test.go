@@ -0,0 +1,127 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestFilesService_Download(t *testing.T) {
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		if r.Method != "GET" {
+			t.Errorf("Expected 'GET' request, got '%s'", r.Method)
+		}
+		if r.URL.Path != "/files/file_123/content" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("anthropic-beta") != BetaFilesAPI {
+			t.Errorf("Expected beta header %s, got %s", BetaFilesAPI, r.Header.Get("anthropic-beta"))
+		}
+		return testResponse{header: http.Header{"Content-Type": {"text/csv"}}, body: "a,b\n1,2\n"}
+	})
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	body, err := client.Files().Download(context.Background(), "file_123")
+	if err != nil {
+		t.Fatalf("Failed to download file: %v", err)
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("Failed to read file: %v", err)
+	}
+	if string(data) != "a,b\n1,2\n" {
+		t.Errorf("Unexpected file content %q", string(data))
+	}
+}
+
+func TestFilesService_DownloadNotFound(t *testing.T) {
+	server := newTestServer(t, respondWith(testResponse{status: http.StatusNotFound, body: `{"type":"error","error":{"type":"not_found_error","message":"file not found"}}`}))
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	_, err := client.Files().Download(context.Background(), "file_missing")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Type != ErrorTypeNotFound {
+		t.Fatalf("Expected not_found_error APIError, got %v", err)
+	}
+}
+
+func TestFilesService_DownloadBetas(t *testing.T) {
+	var beta string
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		beta = r.Header.Get("anthropic-beta")
+		return testResponse{body: "data"}
+	})
+	defer server.Close()
+
+	client, _ := NewClient(
+		WithAPIKey("test-key"),
+		WithBaseURL(server.URL),
+		WithBetaFeatures(BetaPromptCaching),
+		WithHeader("anthropic-beta", "custom-beta"),
+	)
+	body, err := client.Files().Download(context.Background(), "file_123")
+	if err != nil {
+		t.Fatalf("Failed to download file: %v", err)
+	}
+	body.Close()
+
+	expected := BetaPromptCaching + "," + BetaFilesAPI + ",custom-beta"
+	if beta != expected {
+		t.Errorf("Expected beta header %s, got %s", expected, beta)
+	}
+}
+
+func TestFilesService_DownloadRateLimited(t *testing.T) {
+	server := newTestServer(t, respondWith(testResponse{
+		status: http.StatusTooManyRequests,
+		header: http.Header{"Anthropic-Ratelimit-Requests-Limit": {"50"}},
+		body:   `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`,
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	_, err := client.Files().Download(context.Background(), "file_123")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected a rate limit APIError, got %v", err)
+	}
+	if apiErr.RateLimit.RequestsLimit != 50 {
+		t.Errorf("Expected the rate limit info to be parsed, got %+v", apiErr.RateLimit)
+	}
+}
+
+func TestToolOutputFileIDs(t *testing.T) {
+	event := map[string]interface{}{
+		"index": float64(0),
+		"content_block": map[string]interface{}{
+			"type":         "tool_result",
+			"tool_call_id": "call_123",
+			"content": []interface{}{
+				map[string]interface{}{"type": "text", "text": "wrote report"},
+				map[string]interface{}{"type": "file", "file_id": "file_123"},
+			},
+		},
+	}
+
+	response, err := handleContentBlockStartEvent(event, Message{})
+	if err != nil {
+		t.Fatalf("handleContentBlockStartEvent returned an error: %v", err)
+	}
+	output := response.Content[0].ToolOutput
+	if len(output.Content) != 2 {
+		t.Fatalf("Expected 2 tool result content blocks, got %d", len(output.Content))
+	}
+	ids := output.FileIDs()
+	if len(ids) != 1 || ids[0] != "file_123" {
+		t.Errorf("Expected file IDs [file_123], got %v", ids)
+	}
+}
@@ -0,0 +1,94 @@
+package anthropic
+
+import (
+	"context"
+	"sync"
+)
+
+// RequestGroup issues requests associated with a caller-defined key, so that
+// in-flight requests can be cancelled by key without plumbing contexts through
+// the caller, e.g. to stop "the current chat's generation" from a UI handler.
+type RequestGroup struct {
+	client *Client
+
+	mu       sync.Mutex
+	nextID   uint64
+	inFlight map[string]map[uint64]context.CancelFunc
+}
+
+// NewRequestGroup creates a RequestGroup that sends requests with the given client.
+func NewRequestGroup(client *Client) *RequestGroup {
+	return &RequestGroup{
+		client:   client,
+		inFlight: make(map[string]map[uint64]context.CancelFunc),
+	}
+}
+
+// Create sends a message request tracked under key. Cancelling the key aborts the
+// request, in which case the returned error wraps context.Canceled.
+func (g *RequestGroup) Create(ctx context.Context, key string, params *MessageParams) (*Message, error) {
+	ctx, done := g.track(ctx, key)
+	defer done()
+	return g.client.Create(ctx, params)
+}
+
+// Cancel aborts all in-flight requests tracked under key and reports whether any were found.
+func (g *RequestGroup) Cancel(key string) bool {
+	g.mu.Lock()
+	requests := g.inFlight[key]
+	delete(g.inFlight, key)
+	g.mu.Unlock()
+
+	for _, cancel := range requests {
+		cancel()
+	}
+	return len(requests) > 0
+}
+
+// CancelAll aborts every in-flight request in the group.
+func (g *RequestGroup) CancelAll() {
+	g.mu.Lock()
+	inFlight := g.inFlight
+	g.inFlight = make(map[string]map[uint64]context.CancelFunc)
+	g.mu.Unlock()
+
+	for _, requests := range inFlight {
+		for _, cancel := range requests {
+			cancel()
+		}
+	}
+}
+
+// InFlight returns the number of requests currently tracked under key.
+func (g *RequestGroup) InFlight(key string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.inFlight[key])
+}
+
+// track registers a cancellable context under key and returns a function that
+// releases it once the request completes.
+func (g *RequestGroup) track(ctx context.Context, key string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	g.mu.Lock()
+	id := g.nextID
+	g.nextID++
+	if g.inFlight[key] == nil {
+		g.inFlight[key] = make(map[uint64]context.CancelFunc)
+	}
+	g.inFlight[key][id] = cancel
+	g.mu.Unlock()
+
+	return ctx, func() {
+		g.mu.Lock()
+		if requests, ok := g.inFlight[key]; ok {
+			delete(requests, id)
+			if len(requests) == 0 {
+				delete(g.inFlight, key)
+			}
+		}
+		g.mu.Unlock()
+		cancel()
+	}
+}
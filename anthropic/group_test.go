@@ -0,0 +1,69 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRequestGroupCancel(t *testing.T) {
+	started := make(chan struct{}, 2)
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		var params MessageParams
+		_ = json.NewDecoder(r.Body).Decode(&params)
+		if params.Model == string(ModelHaiku) {
+			return testResponse{value: Message{ID: "msg_fast", Role: "assistant"}}
+		}
+		started <- struct{}{}
+		<-r.Context().Done()
+		return testResponse{}
+	})
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	group := NewRequestGroup(client)
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := group.Create(context.Background(), "chat-1", &MessageParams{Model: string(ModelOpus)})
+		errs <- err
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for request to start")
+	}
+	if group.InFlight("chat-1") != 1 {
+		t.Fatalf("Expected 1 in-flight request, got %d", group.InFlight("chat-1"))
+	}
+
+	// Requests under other keys are unaffected.
+	message, err := group.Create(context.Background(), "chat-2", &MessageParams{Model: string(ModelHaiku)})
+	if err != nil || message.ID != "msg_fast" {
+		t.Fatalf("Expected chat-2 request to succeed, got %v, %v", message, err)
+	}
+
+	if !group.Cancel("chat-1") {
+		t.Errorf("Expected Cancel to find the in-flight request")
+	}
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for request to be cancelled")
+	}
+
+	if group.Cancel("chat-1") {
+		t.Errorf("Expected no in-flight requests after cancellation")
+	}
+	if group.InFlight("chat-2") != 0 {
+		t.Errorf("Expected completed requests to be released")
+	}
+}
@@ -0,0 +1,100 @@
+package anthropic
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Supported image media types.
+const (
+	MediaTypePNG  = "image/png"
+	MediaTypeJPEG = "image/jpeg"
+	MediaTypeGIF  = "image/gif"
+	MediaTypeWebP = "image/webp"
+)
+
+var supportedImageMediaTypes = map[string]bool{
+	MediaTypePNG:  true,
+	MediaTypeJPEG: true,
+	MediaTypeGIF:  true,
+	MediaTypeWebP: true,
+}
+
+var imageExtensionMediaTypes = map[string]string{
+	".png":  MediaTypePNG,
+	".jpg":  MediaTypeJPEG,
+	".jpeg": MediaTypeJPEG,
+	".gif":  MediaTypeGIF,
+	".webp": MediaTypeWebP,
+}
+
+// NewImageBlock creates an image content block from base64-encoded data,
+// validating the data and media type.
+func NewImageBlock(mediaType, data string) (ContentBlock, error) {
+	image := &Image{Type: "base64", MediaType: mediaType, Data: data}
+	if err := ValidateImage(image); err != nil {
+		return ContentBlock{}, err
+	}
+	return ContentBlock{Type: "image", Source: image}, nil
+}
+
+// NewImageBlockFromBytes creates an image content block from raw image bytes.
+func NewImageBlockFromBytes(mediaType string, data []byte) (ContentBlock, error) {
+	if len(data) == 0 {
+		return ContentBlock{}, fmt.Errorf("image data is empty")
+	}
+	return NewImageBlock(mediaType, base64.StdEncoding.EncodeToString(data))
+}
+
+// NewImageBlockFromFile creates an image content block from a file, inferring the
+// media type from the file extension. Files without a recognized image extension,
+// such as temporary files, have their media type detected from their content.
+func NewImageBlockFromFile(path string) (ContentBlock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ContentBlock{}, fmt.Errorf("error reading image file: %w", err)
+	}
+	mediaType, ok := imageExtensionMediaTypes[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		mediaType, err = DetectImageMediaType(data)
+		if err != nil {
+			return ContentBlock{}, fmt.Errorf("cannot infer a supported image type of %q from its extension or content: %w", path, err)
+		}
+	}
+	return NewImageBlockFromBytes(mediaType, data)
+}
+
+// DetectImageMediaType detects the media type of raw image data by sniffing its
+// content, returning an error unless it is a supported image type.
+func DetectImageMediaType(data []byte) (string, error) {
+	mediaType := http.DetectContentType(data)
+	if !supportedImageMediaTypes[mediaType] {
+		return "", fmt.Errorf("detected content type %q is not a supported image type", mediaType)
+	}
+	return mediaType, nil
+}
+
+// ValidateImage checks that the image has a supported media type and, for base64
+// sources, valid base64 data.
+func ValidateImage(image *Image) error {
+	if image == nil {
+		return fmt.Errorf("image source is missing")
+	}
+	if !supportedImageMediaTypes[image.MediaType] {
+		return fmt.Errorf("unsupported image media type %q: must be one of image/png, image/jpeg, image/gif, image/webp", image.MediaType)
+	}
+	if image.Type != "base64" {
+		return nil
+	}
+	if image.Data == "" {
+		return fmt.Errorf("image data is empty")
+	}
+	if _, err := base64.StdEncoding.DecodeString(image.Data); err != nil {
+		return fmt.Errorf("image data is not valid base64: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package anthropic
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewImageBlock(t *testing.T) {
+	validData := base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\n"))
+
+	testCases := []struct {
+		name        string
+		mediaType   string
+		data        string
+		errContains string
+	}{
+		{name: "Valid PNG", mediaType: MediaTypePNG, data: validData},
+		{name: "Valid WebP", mediaType: MediaTypeWebP, data: validData},
+		{name: "Unsupported media type", mediaType: "image/bmp", data: validData, errContains: "unsupported image media type"},
+		{name: "Invalid base64", mediaType: MediaTypeJPEG, data: "not*base64!", errContains: "not valid base64"},
+		{name: "Empty data", mediaType: MediaTypeGIF, data: "", errContains: "empty"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			block, err := NewImageBlock(tc.mediaType, tc.data)
+			if tc.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.errContains) {
+					t.Errorf("Expected error containing %q, got %v", tc.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if block.Type != "image" || block.Source.Type != "base64" || block.Source.MediaType != tc.mediaType {
+				t.Errorf("Unexpected image block: %+v", block)
+			}
+		})
+	}
+}
+
+func TestNewImageBlockFromFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "photo.JPG")
+	if err := os.WriteFile(path, []byte("\xff\xd8\xff\xe0"), 0o600); err != nil {
+		t.Fatalf("Failed to write image file: %v", err)
+	}
+
+	block, err := NewImageBlockFromFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if block.Source.MediaType != MediaTypeJPEG {
+		t.Errorf("Expected media type %s, got %s", MediaTypeJPEG, block.Source.MediaType)
+	}
+	if block.Source.Data != base64.StdEncoding.EncodeToString([]byte("\xff\xd8\xff\xe0")) {
+		t.Errorf("Unexpected image data %s", block.Source.Data)
+	}
+
+	notes := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(notes, []byte("just some notes"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := NewImageBlockFromFile(notes); err == nil {
+		t.Errorf("Expected an error for an unsupported extension and content")
+	}
+}
+
+func TestNewImageBlockFromFileSniffsContent(t *testing.T) {
+	testCases := []struct {
+		name          string
+		data          string
+		expectedType  string
+		expectedError bool
+	}{
+		{name: "PNG", data: "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR", expectedType: MediaTypePNG},
+		{name: "JPEG", data: "\xff\xd8\xff\xe0", expectedType: MediaTypeJPEG},
+		{name: "GIF", data: "GIF89a\x01\x00\x01\x00", expectedType: MediaTypeGIF},
+		{name: "WebP", data: "RIFF\x00\x00\x00\x00WEBPVP8 ", expectedType: MediaTypeWebP},
+		{name: "Not an image", data: "%PDF-1.7", expectedError: true},
+	}
+
+	dir := t.TempDir()
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("upload-%d", i))
+			if err := os.WriteFile(path, []byte(tc.data), 0o600); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+
+			block, err := NewImageBlockFromFile(path)
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("Expected an error, got media type %s", block.Source.MediaType)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if block.Source.MediaType != tc.expectedType {
+				t.Errorf("Expected media type %s, got %s", tc.expectedType, block.Source.MediaType)
+			}
+		})
+	}
+}
+
+func TestMessageParamsValidateImages(t *testing.T) {
+	params := &MessageParams{
+		Model: string(ModelSonnet),
+		Messages: []MessageParam{
+			{
+				Role: "user",
+				Content: []ContentBlock{
+					{Type: "text", Text: "Describe this"},
+					{Type: "image", Source: &Image{Type: "base64", MediaType: MediaTypePNG, Data: "%%%"}},
+				},
+			},
+		},
+	}
+	err := params.Validate()
+	if err == nil || !strings.Contains(err.Error(), "message 0, content block 1") {
+		t.Errorf("Expected an error locating the invalid image, got %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package anthropic
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// defaultMaxResponseBytes caps non-streaming response bodies. Even the longest
+	// possible message is a small fraction of it, so a larger body is suspicious.
+	defaultMaxResponseBytes = 32 << 20
+	// defaultMaxStreamBytes caps event streams, whose per-event framing makes long
+	// responses several times larger than the equivalent non-streaming body.
+	defaultMaxStreamBytes = 256 << 20
+)
+
+// ErrResponseTooLarge is returned when a response body exceeds the limit set with
+// WithMaxResponseBytes or WithMaxStreamBytes.
+var ErrResponseTooLarge = errors.New("response body exceeds the maximum size")
+
+// WithMaxResponseBytes caps the number of bytes read from a non-streaming messages
+// response body. The body is decoded incrementally, but the decoded Message holds the
+// full content in memory, so a very large response costs roughly its size twice.
+// Reading stops with an error wrapping ErrResponseTooLarge once the limit is exceeded.
+// The default is 32 MiB; a limit of zero disables the cap.
+func WithMaxResponseBytes(n int64) ClientOption {
+	return func(c *Client) error {
+		if n < 0 {
+			return fmt.Errorf("max response bytes must not be negative, got %d", n)
+		}
+		c.maxResponseBytes = n
+		return nil
+	}
+}
+
+// WithMaxStreamBytes caps the cumulative number of bytes read from the event stream
+// of a streaming messages response. Reading stops with an error wrapping
+// ErrResponseTooLarge once the limit is exceeded. The default, 256 MiB, is higher than
+// the non-streaming default so that legitimately long streams are not cut short; a
+// limit of zero disables the cap.
+func WithMaxStreamBytes(n int64) ClientOption {
+	return func(c *Client) error {
+		if n < 0 {
+			return fmt.Errorf("max stream bytes must not be negative, got %d", n)
+		}
+		c.maxStreamBytes = n
+		return nil
+	}
+}
+
+// limitResponseBody wraps body so that reading past limit bytes fails with
+// ErrResponseTooLarge. A limit of zero returns body unchanged.
+func limitResponseBody(body io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return body
+	}
+	return &maxBytesReader{r: body, remaining: limit}
+}
+
+// maxBytesReader reads from r until remaining bytes have been consumed, then fails
+// if r still has data.
+type maxBytesReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if m.remaining <= 0 {
+		var probe [1]byte
+		n, err := m.r.Read(probe[:])
+		if n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > m.remaining {
+		p = p[:m.remaining]
+	}
+	n, err := m.r.Read(p)
+	m.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,111 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWithMaxResponseBytesNonStreaming(t *testing.T) {
+	body := `{"id":"msg_123","role":"assistant","content":[{"type":"text","text":"` + strings.Repeat("a", 1024) + `"}]}`
+	server := newTestServer(t, respondWith(testResponse{body: body}))
+	defer server.Close()
+
+	testCases := []struct {
+		name      string
+		limit     int64
+		expectErr bool
+	}{
+		{name: "No limit", limit: 0},
+		{name: "Limit equal to body size", limit: int64(len(body))},
+		{name: "Limit below body size", limit: 512, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL), WithMaxResponseBytes(tc.limit))
+			message, err := client.Messages().Create(context.Background(), &MessageParams{Model: string(ModelSonnet)})
+			if tc.expectErr {
+				if !errors.Is(err, ErrResponseTooLarge) {
+					t.Fatalf("Expected ErrResponseTooLarge, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(message.Text()) != 1024 {
+				t.Errorf("Expected 1024 characters of text, got %d", len(message.Text()))
+			}
+		})
+	}
+}
+
+func TestWithMaxStreamBytes(t *testing.T) {
+	server := newTestServer(t, respondWith(testResponse{events: helloStreamEvents}))
+	defer server.Close()
+
+	testCases := []struct {
+		name      string
+		opts      []ClientOption
+		expectErr bool
+	}{
+		{name: "Default limit", opts: nil},
+		{name: "Stream limit exceeded", opts: []ClientOption{WithMaxStreamBytes(256)}, expectErr: true},
+		{name: "Response limit does not apply", opts: []ClientOption{WithMaxResponseBytes(256)}},
+		{name: "Stream limit disabled", opts: []ClientOption{WithMaxStreamBytes(0)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, _ := NewClient(append([]ClientOption{WithAPIKey("test-key"), WithBaseURL(server.URL)}, tc.opts...)...)
+			params := &MessageParams{
+				Model:      string(ModelSonnet),
+				StreamFunc: func(ctx context.Context, chunk []byte) error { return nil },
+			}
+			_, err := client.Messages().Create(context.Background(), params)
+			if tc.expectErr {
+				if !errors.Is(err, ErrResponseTooLarge) {
+					t.Errorf("Expected ErrResponseTooLarge, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestMaxBytesDefaults(t *testing.T) {
+	client, _ := NewClient(WithAPIKey("test-key"))
+	if client.maxResponseBytes != defaultMaxResponseBytes || client.maxStreamBytes != defaultMaxStreamBytes {
+		t.Errorf("Expected default limits %d and %d, got %d and %d", defaultMaxResponseBytes, defaultMaxStreamBytes, client.maxResponseBytes, client.maxStreamBytes)
+	}
+	if client.maxStreamBytes <= client.maxResponseBytes {
+		t.Error("Expected the default stream limit to be higher than the response limit")
+	}
+}
+
+func TestWithMaxBytesNegative(t *testing.T) {
+	if _, err := NewClient(WithAPIKey("test-key"), WithMaxResponseBytes(-1)); err == nil {
+		t.Error("Expected an error for a negative response limit")
+	}
+	if _, err := NewClient(WithAPIKey("test-key"), WithMaxStreamBytes(-1)); err == nil {
+		t.Error("Expected an error for a negative stream limit")
+	}
+}
+
+func TestMaxBytesReader(t *testing.T) {
+	data, err := io.ReadAll(limitResponseBody(strings.NewReader("hello"), 5))
+	if err != nil || string(data) != "hello" {
+		t.Errorf("Expected to read %q within the limit, got %q (%v)", "hello", data, err)
+	}
+
+	data, err = io.ReadAll(limitResponseBody(strings.NewReader("hello!"), 5))
+	if !errors.Is(err, ErrResponseTooLarge) || string(data) != "hello" {
+		t.Errorf("Expected ErrResponseTooLarge after %q, got %q (%v)", "hello", data, err)
+	}
+}
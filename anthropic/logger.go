@@ -0,0 +1,90 @@
+package anthropic
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogLevel represents the severity of a log entry.
+type LogLevel int
+
+// Supported log levels, in increasing order of severity.
+const (
+	LogLevelDebug LogLevel = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+// String returns the lower-case name of the log level.
+func (l LogLevel) String() string {
+	switch l {
+	case LogLevelDebug:
+		return "debug"
+	case LogLevelInfo:
+		return "info"
+	case LogLevelWarn:
+		return "warn"
+	case LogLevelError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Logger is the interface used by the client to emit log entries.
+// Fields are passed as alternating key/value pairs, following the slog convention.
+type Logger interface {
+	// Enabled reports whether entries at the given level are emitted.
+	Enabled(ctx context.Context, level LogLevel) bool
+	// Log emits a single entry with optional structured fields.
+	Log(ctx context.Context, level LogLevel, msg string, fields ...interface{})
+}
+
+// noopLogger discards all log entries.
+type noopLogger struct{}
+
+func (noopLogger) Enabled(context.Context, LogLevel) bool                { return false }
+func (noopLogger) Log(context.Context, LogLevel, string, ...interface{}) {}
+
+// slogLogger adapts a *slog.Logger to the Logger interface.
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger returns a Logger backed by the given *slog.Logger.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &slogLogger{logger: logger}
+}
+
+func (l *slogLogger) Enabled(ctx context.Context, level LogLevel) bool {
+	return l.logger.Enabled(ctx, toSlogLevel(level))
+}
+
+func (l *slogLogger) Log(ctx context.Context, level LogLevel, msg string, fields ...interface{}) {
+	l.logger.Log(ctx, toSlogLevel(level), msg, fields...)
+}
+
+func toSlogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelWarn:
+		return slog.LevelWarn
+	case LogLevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// log emits an entry through the client's logger if the level is enabled.
+func (c *Client) log(ctx context.Context, level LogLevel, msg string, fields ...interface{}) {
+	if c.logger == nil || !c.logger.Enabled(ctx, level) {
+		return
+	}
+	c.logger.Log(ctx, level, msg, fields...)
+}
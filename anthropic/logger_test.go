@@ -0,0 +1,138 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogLoggerLevels(t *testing.T) {
+	testCases := []struct {
+		name        string
+		level       slog.Level
+		expectBody  bool
+		expectEntry bool
+	}{
+		{name: "Debug logs bodies", level: slog.LevelDebug, expectBody: true, expectEntry: true},
+		{name: "Info omits bodies", level: slog.LevelInfo, expectBody: false, expectEntry: true},
+		{name: "Error omits everything on success", level: slog.LevelError, expectBody: false, expectEntry: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, respondWith(testResponse{value: Message{ID: "msg_123", Role: "assistant"}}))
+			defer server.Close()
+
+			var buf bytes.Buffer
+			handler := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: tc.level})
+			client, err := NewClient(
+				WithAPIKey("test-key"),
+				WithBaseURL(server.URL),
+				WithSlogLogger(slog.New(handler)),
+			)
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+
+			params := &MessageParams{
+				Model: string(ModelSonnet),
+				Messages: []MessageParam{
+					{Role: "user", Content: []ContentBlock{{Type: "text", Text: "secret prompt"}}},
+				},
+			}
+			if _, err := client.Messages().Create(context.Background(), params); err != nil {
+				t.Fatalf("Failed to create message: %v", err)
+			}
+
+			output := buf.String()
+			if got := strings.Contains(output, "secret prompt"); got != tc.expectBody {
+				t.Errorf("Expected body logged=%v, got output: %s", tc.expectBody, output)
+			}
+			if got := strings.Contains(output, "received response"); got != tc.expectEntry {
+				t.Errorf("Expected response entry logged=%v, got output: %s", tc.expectEntry, output)
+			}
+		})
+	}
+}
+
+func TestSlogLoggerStructuredFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	logger.Log(context.Background(), LogLevelWarn, "hello", "status", 429, "model", "claude")
+
+	output := buf.String()
+	for _, want := range []string{"level=WARN", "msg=hello", "status=429", "model=claude"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected output to contain %q, got %s", want, output)
+		}
+	}
+}
+
+func TestLogLevelString(t *testing.T) {
+	testCases := map[LogLevel]string{
+		LogLevelDebug: "debug",
+		LogLevelInfo:  "info",
+		LogLevelWarn:  "warn",
+		LogLevelError: "error",
+		LogLevel(42):  "unknown",
+	}
+	for level, expected := range testCases {
+		if level.String() != expected {
+			t.Errorf("Expected %q, got %q", expected, level.String())
+		}
+	}
+}
+
+func TestUnexpectedRoleLogsWarning(t *testing.T) {
+	server := newTestServer(t, respondWith(testResponse{events: []string{
+		`{"type":"message_start","message":{"id":"msg_123","role":"system","usage":{"input_tokens":10}}}`,
+		`{"type":"message_stop"}`,
+	}}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client, _ := NewClient(
+		WithAPIKey("test-key"),
+		WithBaseURL(server.URL),
+		WithSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))),
+	)
+	params := &MessageParams{
+		Model:      string(ModelSonnet),
+		StreamFunc: func(ctx context.Context, chunk []byte) error { return nil },
+	}
+	if _, err := client.Messages().Create(context.Background(), params); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if output := buf.String(); !strings.Contains(output, "unexpected message role") || !strings.Contains(output, "role=system") {
+		t.Errorf("Expected a warning about the role, got %s", output)
+	}
+}
+
+func TestUnknownStreamEventLogsDebug(t *testing.T) {
+	server := newTestServer(t, respondWith(testResponse{events: []string{
+		`{"type":"message_start","message":{"id":"msg_123","role":"assistant","usage":{"input_tokens":10}}}`,
+		`{"type":"future_event"}`,
+		`{"type":"message_stop"}`,
+	}}))
+	defer server.Close()
+
+	var buf bytes.Buffer
+	client, _ := NewClient(
+		WithAPIKey("test-key"),
+		WithBaseURL(server.URL),
+		WithSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+	params := &MessageParams{
+		Model:      string(ModelSonnet),
+		StreamFunc: func(ctx context.Context, chunk []byte) error { return nil },
+	}
+	if _, err := client.Messages().Create(context.Background(), params); err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if output := buf.String(); !strings.Contains(output, "unknown stream event type") || !strings.Contains(output, "type=future_event") {
+		t.Errorf("Expected a debug entry about the event, got %s", output)
+	}
+}
@@ -0,0 +1,89 @@
+package anthropic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// MergeMessages merges messages that continue one another, such as the parts of a
+// response continued after hitting max_tokens, into a single message. Content blocks
+// are concatenated, with adjacent text blocks coalesced into one; usage is summed.
+// The ID and model are taken from the first message, and the stop reason, stop
+// sequence and response metadata from the last. Nil messages are skipped; MergeMessages
+// returns nil if there is no message to merge. The inputs are not modified.
+func MergeMessages(msgs ...*Message) *Message {
+	var merged *Message
+	for _, m := range msgs {
+		if m == nil {
+			continue
+		}
+		if merged == nil {
+			first := *m
+			first.Content = nil
+			first.Usage = Usage{}
+			merged = &first
+		}
+		for _, block := range m.Content {
+			last := len(merged.Content) - 1
+			if block.Type == "text" && last >= 0 && merged.Content[last].Type == "text" {
+				merged.Content[last].Text += block.Text
+				merged.Content[last].Logprobs = append(merged.Content[last].Logprobs, block.Logprobs...)
+				continue
+			}
+			if block.Type == "text" {
+				block.Logprobs = append([]TokenLogprob(nil), block.Logprobs...)
+			}
+			merged.Content = append(merged.Content, block)
+		}
+		merged.Usage = merged.Usage.Add(m.Usage)
+		merged.StopReason = m.StopReason
+		merged.StopSequence = m.StopSequence
+		merged.Meta = m.Meta
+		if m.Container != nil {
+			merged.Container = m.Container
+		}
+	}
+	return merged
+}
+
+// CreateComplete sends params and, for as long as the response stops because it hit
+// max_tokens while writing text, asks the model to continue where it left off, up to
+// maxContinuations times. The parts are merged with MergeMessages into one message.
+//
+// Each continuation resends the conversation with the text generated so far as a
+// final assistant turn, so its input tokens are billed again. As the API rejects an
+// assistant turn ending in whitespace, trailing whitespace generated at a cut-off
+// point is dropped. The params must not already end with an assistant turn. The
+// params are not modified.
+func (s *MessagesService) CreateComplete(ctx context.Context, params *MessageParams, maxContinuations int) (*Message, error) {
+	if n := len(params.Messages); n > 0 && params.Messages[n-1].Role == string(RoleAssistant) {
+		return nil, fmt.Errorf("CreateComplete cannot continue a conversation ending with an assistant turn")
+	}
+
+	message, err := s.Create(ctx, params)
+	if err != nil {
+		return message, err
+	}
+	for i := 0; i < maxContinuations && canContinue(message); i++ {
+		last := len(message.Content) - 1
+		message.Content[last].Text = strings.TrimRight(message.Content[last].Text, " \t\r\n")
+
+		continuation := *params
+		continuation.Messages = append(append([]MessageParam(nil), params.Messages...), message.ToParam())
+		next, err := s.Create(ctx, &continuation)
+		if err != nil {
+			return MergeMessages(message, next), fmt.Errorf("error continuing message after %d continuations: %w", i, err)
+		}
+		message = MergeMessages(message, next)
+	}
+	return message, nil
+}
+
+// canContinue reports whether message was cut off by max_tokens while writing text,
+// which the model can resume from an assistant turn holding the text so far.
+func canContinue(message *Message) bool {
+	n := len(message.Content)
+	return message.StopReason == "max_tokens" && n > 0 && message.Content[n-1].Type == "text" &&
+		strings.TrimSpace(message.Content[n-1].Text) != ""
+}
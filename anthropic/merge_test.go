@@ -0,0 +1,105 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestMergeMessages(t *testing.T) {
+	first := &Message{
+		ID:         "msg_1",
+		Role:       "assistant",
+		Model:      string(ModelSonnet),
+		Content:    []ContentBlock{TextContent("The quick brown")},
+		StopReason: "max_tokens",
+		Usage:      Usage{InputTokens: 10, OutputTokens: 3, CacheReadInputTokens: 5},
+	}
+	second := &Message{
+		ID:         "msg_2",
+		Role:       "assistant",
+		Model:      string(ModelSonnet),
+		Content:    []ContentBlock{TextContent(" fox jumps"), ToolUseContent("toolu_1", "search", json.RawMessage(`{}`))},
+		StopReason: "tool_use",
+		Usage:      Usage{InputTokens: 13, OutputTokens: 4},
+		Meta:       &ResponseMeta{RequestID: "req_2"},
+	}
+
+	merged := MergeMessages(first, nil, second)
+	expected := &Message{
+		ID:         "msg_1",
+		Role:       "assistant",
+		Model:      string(ModelSonnet),
+		Content:    []ContentBlock{TextContent("The quick brown fox jumps"), ToolUseContent("toolu_1", "search", json.RawMessage(`{}`))},
+		StopReason: "tool_use",
+		Usage:      Usage{InputTokens: 23, OutputTokens: 7, CacheReadInputTokens: 5},
+		Meta:       &ResponseMeta{RequestID: "req_2"},
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, merged)
+	}
+	if first.Text() != "The quick brown" || len(first.Content) != 1 {
+		t.Errorf("Expected the inputs to be left unchanged, got %+v", first)
+	}
+
+	if MergeMessages() != nil || MergeMessages(nil) != nil {
+		t.Error("Expected nil when there is no message to merge")
+	}
+}
+
+func TestMessagesService_CreateComplete(t *testing.T) {
+	var requests []MessageParams
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		var params MessageParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		requests = append(requests, params)
+
+		switch len(requests) {
+		case 1:
+			return testResponse{body: `{"id":"msg_1","role":"assistant","content":[{"type":"text","text":"One, two, "}],"stop_reason":"max_tokens","usage":{"input_tokens":10,"output_tokens":4}}`}
+		case 2:
+			return testResponse{body: `{"id":"msg_2","role":"assistant","content":[{"type":"text","text":" three, "}],"stop_reason":"max_tokens","usage":{"input_tokens":14,"output_tokens":3}}`}
+		default:
+			return testResponse{body: `{"id":"msg_3","role":"assistant","content":[{"type":"text","text":" four."}],"stop_reason":"end_turn","usage":{"input_tokens":17,"output_tokens":2}}`}
+		}
+	})
+	defer server.Close()
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	params := &MessageParams{
+		Model:     string(ModelSonnet),
+		MaxTokens: 4,
+		Messages:  []MessageParam{{Role: "user", Content: []ContentBlock{TextContent("Count to four.")}}},
+	}
+	message, err := client.Messages().CreateComplete(context.Background(), params, 5)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if message.Text() != "One, two, three, four." || message.StopReason != "end_turn" {
+		t.Errorf("Expected the merged text and final stop reason, got %q (%s)", message.Text(), message.StopReason)
+	}
+	if message.Usage.OutputTokens != 9 || message.ID != "msg_1" {
+		t.Errorf("Expected summed usage and the first ID, got %+v (%s)", message.Usage, message.ID)
+	}
+
+	if len(requests) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(requests))
+	}
+	continued := requests[2].Messages
+	if len(continued) != 2 || continued[1].Role != "assistant" || continued[1].Content[0].Text != "One, two, three," {
+		t.Errorf("Expected the text so far without trailing whitespace as the final assistant turn, got %+v", continued)
+	}
+	if len(params.Messages) != 1 {
+		t.Error("Expected the caller's params to be left unchanged")
+	}
+
+	requests = nil
+	message, err = client.Messages().CreateComplete(context.Background(), params, 0)
+	if err != nil || message.StopReason != "max_tokens" || len(requests) != 1 {
+		t.Errorf("Expected no continuation with a limit of 0, got %d requests (error %v)", len(requests), err)
+	}
+}
@@ -1,38 +1,235 @@
 package anthropic
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
+	"time"
 )
 
 const messagesEndpoint = "/messages"
 
+// WithCallTimeout returns a copy of ctx that expires after d, for bounding a single
+// call without changing the client-wide timeout. The caller must call the returned
+// cancel function once the call completes.
+//
+// For non-streaming calls the deadline covers sending the request and decoding the
+// full response. For streaming calls it covers the entire stream, not only the time
+// to the first event: if it expires mid-stream, reading stops and Create returns an
+// error wrapping context.DeadlineExceeded.
+func WithCallTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, d)
+}
+
 // Create sends a request to create a new message.
 // It handles both streaming and non-streaming responses based on the MessageParams.
+// If params.Model is empty and a model router is configured, the router picks the model.
+// Sampling defaults set on the client fill in Temperature, TopP and TopK if they are nil.
+// With WithClampMaxTokens, MaxTokens is lowered to the limit of each model tried.
+// If a model fallback chain is configured, an overloaded or unavailable model causes
+// the request to be retried with the next model in the chain.
+//
+// If the context of a streaming request is cancelled or times out mid-stream, Create
+// returns the partially assembled message along with an error wrapping ctx.Err().
 func (s *Client) Create(ctx context.Context, params *MessageParams) (*Message, error) {
-	url := s.baseURL + messagesEndpoint
+	if params.Model == "" && s.modelRouter != nil {
+		routed := *params
+		routed.Model = string(s.modelRouter(params))
+		s.log(ctx, LogLevelDebug, "routed request", "model", routed.Model)
+		params = &routed
+	}
+	params = s.withCachedSystemPrompt(params)
+	params = s.withSamplingDefaults(params)
+
+	message, err := s.create(ctx, params)
+	if err == nil || len(s.fallbackModels) == 0 {
+		return message, err
+	}
+
+	tried := map[string]bool{params.Model: true}
+	for _, model := range s.fallbackModels {
+		if !isModelUnavailable(err) {
+			return message, err
+		}
+		if tried[string(model)] {
+			continue
+		}
+		tried[string(model)] = true
 
-	body, err := json.Marshal(params)
+		s.log(ctx, LogLevelWarn, "model unavailable, falling back", "from", params.Model, "to", string(model), "error", err)
+		fallback := *params
+		fallback.Model = string(model)
+		message, err = s.create(ctx, &fallback)
+		if err == nil {
+			return message, nil
+		}
+	}
+	return nil, err
+}
+
+// create performs a request for the given params, retrying retryable failures.
+func (s *Client) create(ctx context.Context, params *MessageParams) (*Message, error) {
+	params = s.withClampedMaxTokens(ctx, params)
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkCapabilities(ctx, params); err != nil {
+		return nil, err
+	}
+	if s.strictToolResults {
+		if err := validateToolResultOrdering(params.Messages); err != nil {
+			return nil, err
+		}
+	}
+
+	opts := s.callOptions(params)
+	body, err := params.marshal(opts)
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling request body: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	resp, err := s.sendWithRetry(ctx, params, body)
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %w", err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var respBody io.Reader = resp.Body
+	// The transport only decompresses responses it requested compressed itself; a proxy
+	// or a custom Accept-Encoding header can still produce a gzipped body.
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") && !resp.Uncompressed {
+		gzipReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing response: %w", err)
+		}
+		defer gzipReader.Close()
+		respBody = gzipReader
+	}
+	s.checkContentType(ctx, resp, params.IsStreaming())
+	if params.IsStreaming() {
+		respBody = limitResponseBody(respBody, s.maxStreamBytes)
+		message, err := parseStreamingMessageResponse(ctx, respBody, params, opts)
+		if message != nil {
+			message.Meta = parseResponseMeta(resp.Header)
+			s.checkRole(ctx, message)
+			s.observeModel(message)
+			if message.Model == "" {
+				message.Model = params.Model
+			}
+		}
+		return message, err
 	}
 
-	if params.MaxTokens >= 8192 && params.Model == string(ModelSonnet) {
-		req.Header.Set("anthropic-beta", "max-tokens-3-5-sonnet-2024-07-15")
+	respBody = limitResponseBody(respBody, s.maxResponseBytes)
+	// The decoder reads the body incrementally rather than buffering it up front.
+	var debugBody bytes.Buffer
+	if s.logger.Enabled(ctx, LogLevelDebug) {
+		respBody = io.TeeReader(respBody, &debugBody)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Key", s.APIKey)
+	var message Message
+	err = json.NewDecoder(respBody).Decode(&message)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding response: %w", classifyTransportError(ctx, err))
+	}
+	s.log(ctx, LogLevelDebug, "response body", "body", debugBody.String())
+
+	message.Meta = parseResponseMeta(resp.Header)
+	s.checkRole(ctx, &message)
+	s.observeModel(&message)
+	if message.Model == "" {
+		message.Model = params.Model
+	}
+	return &message, nil
+}
+
+// checkContentType logs a warning if the response has no Content-Type header. The
+// body is decoded according to the request regardless: as an event stream for
+// streaming requests and as JSON otherwise.
+func (s *Client) checkContentType(ctx context.Context, resp *http.Response, streaming bool) {
+	if resp.Header.Get("Content-Type") != "" {
+		return
+	}
+	decoding := "JSON"
+	if streaming {
+		decoding = "an event stream"
+	}
+	s.log(ctx, LogLevelWarn, "response has no Content-Type header, decoding it as "+decoding, "status", resp.StatusCode)
+}
+
+// checkRole logs a warning if the role of message is not a known role.
+func (s *Client) checkRole(ctx context.Context, message *Message) {
+	if message.Role != "assistant" && message.Role != "user" {
+		s.log(ctx, LogLevelWarn, "unexpected message role", "role", message.Role, "id", message.ID)
+	}
+}
+
+// observeModel reports the model of message to the unknown model callback if it is
+// not a known model.
+func (s *Client) observeModel(message *Message) {
+	if s.onUnknownModel != nil && message.Model != "" && !IsKnownModel(ModelID(message.Model)) {
+		s.onUnknownModel(message.Model)
+	}
+}
+
+// newRequest builds an API request with the authentication and version headers set.
+func (s *Client) newRequest(ctx context.Context, method, endpoint string, body []byte) (*http.Request, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+endpoint, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	apiKey, err := s.resolveAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-API-Key", apiKey)
 	req.Header.Set("anthropic-version", s.APIVersion)
+	return req, nil
+}
+
+// applyRequestEditors sets the client's custom headers on req, then runs the
+// client's request editors on it. Custom betas are added to those already set.
+func (s *Client) applyRequestEditors(ctx context.Context, req *http.Request) error {
+	for key, values := range s.headers {
+		if key == "Anthropic-Beta" {
+			betas := mergeBetas(strings.Split(req.Header.Get(key), ","), strings.Split(strings.Join(values, ","), ","))
+			req.Header.Set(key, strings.Join(betas, ","))
+			continue
+		}
+		req.Header[key] = append([]string(nil), values...)
+	}
+	for _, editor := range s.requestEditors {
+		if err := editor(ctx, req); err != nil {
+			return fmt.Errorf("request editor returned an error: %w", err)
+		}
+	}
+	return nil
+}
+
+// newMessagesRequest builds a request for the messages endpoint and applies the
+// client's request editors. A non-empty idempotencyKey is sent as a header.
+func (s *Client) newMessagesRequest(ctx context.Context, params *MessageParams, body []byte, idempotencyKey string) (*http.Request, error) {
+	req, err := s.newRequest(ctx, "POST", messagesEndpoint, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if betas := mergeBetas(s.betaFeatures, requestBetas(params), params.BetaFeatures); len(betas) > 0 {
+		req.Header.Set("anthropic-beta", strings.Join(betas, ","))
+	}
 
 	// Set Accep header based on whether streaming is requested
 	if params.IsStreaming() {
@@ -41,26 +238,110 @@ func (s *Client) Create(ctx context.Context, params *MessageParams) (*Message, e
 		req.Header.Set("Accept", "application/json")
 	}
 
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error sending request: %w", err)
+	if idempotencyKey != "" {
+		req.Header.Set(idempotencyKeyHeader, idempotencyKey)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(bodyBytes))
+	if err := s.applyRequestEditors(ctx, req); err != nil {
+		return nil, err
 	}
+	return req, nil
+}
 
-	if params.IsStreaming() {
-		return parseStreamingMessageResponse(ctx, resp.Body, params)
+// requestBetas returns the beta features the request depends on.
+func requestBetas(params *MessageParams) []string {
+	var betas []string
+	if beta, ok := maxTokensBetas[ModelID(params.Model)]; ok && params.MaxTokens >= beta.MinMaxTokens {
+		betas = append(betas, beta.Beta)
+	}
+	for _, tool := range params.Tools {
+		if _, ok := tool.ServerTool.(*WebSearchTool); ok {
+			betas = append(betas, BetaWebSearch)
+			break
+		}
 	}
+	return betas
+}
 
-	var message Message
-	err = json.NewDecoder(resp.Body).Decode(&message)
+// callOptions carries the client configuration that changes how a single request is
+// encoded and its response parsed.
+type callOptions struct {
+	// partialToolInput disables the check that streamed tool inputs are valid JSON
+	// at message_stop, for fine-grained tool streaming.
+	partialToolInput bool
+	// streamSplit, if set, replaces scanSSELines for splitting the event stream.
+	streamSplit bufio.SplitFunc
+	// omitStreamFalse leaves the "stream" field out of non-streaming request bodies.
+	omitStreamFalse bool
+	// logger, if set, receives debug entries from the stream parser.
+	logger Logger
+}
+
+// callOptions returns the options for a request with params.
+func (s *Client) callOptions(params *MessageParams) callOptions {
+	return callOptions{
+		partialToolInput: s.fineGrainedToolStreaming(params),
+		streamSplit:      s.streamSplit,
+		omitStreamFalse:  s.omitStreamFalse,
+		logger:           s.logger,
+	}
+}
+
+// fineGrainedToolStreaming reports whether the fine-grained tool streaming beta is
+// enabled for params, on the client or on the request.
+func (s *Client) fineGrainedToolStreaming(params *MessageParams) bool {
+	for _, beta := range mergeBetas(s.betaFeatures, params.BetaFeatures) {
+		if beta == BetaFineGrainedToolStreaming {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeBetas concatenates the given beta feature lists, dropping empty and duplicate
+// entries while keeping the first occurrence's position.
+func mergeBetas(lists ...[]string) []string {
+	var merged []string
+	seen := make(map[string]bool)
+	for _, list := range lists {
+		for _, beta := range list {
+			beta = strings.TrimSpace(beta)
+			if beta == "" || seen[beta] {
+				continue
+			}
+			seen[beta] = true
+			merged = append(merged, beta)
+		}
+	}
+	return merged
+}
+
+// doRequest sends req once and converts a non-200 response into an *APIError.
+// The body is the request payload, used for logging only.
+// On success the caller is responsible for closing the response body.
+func (s *Client) doRequest(ctx context.Context, req *http.Request, body []byte, params *MessageParams) (*http.Response, error) {
+	url := req.URL.String()
+	s.log(ctx, LogLevelDebug, "sending request", "method", req.Method, "url", url, "body", string(body))
+
+	resp, err := s.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error decoding response: %w", err)
+		s.log(ctx, LogLevelError, "request failed", "url", url, "error", err)
+		return nil, fmt.Errorf("error sending request: %w", classifyTransportError(ctx, err))
 	}
 
-	return &message, nil
+	s.log(ctx, LogLevelInfo, "received response", "status", resp.StatusCode, "model", params.Model, "streaming", params.IsStreaming())
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusUnauthorized && s.apiKeyFunc != nil {
+			s.apiKeyFunc.invalidate()
+		}
+		s.log(ctx, LogLevelError, "API request failed", "status", resp.StatusCode)
+		s.log(ctx, LogLevelDebug, "error response body", "body", string(bodyBytes))
+		apiErr := newAPIError(resp.StatusCode, bodyBytes)
+		apiErr.RateLimit = parseRateLimitInfo(resp.Header)
+		return nil, apiErr
+	}
+	return resp, nil
 }
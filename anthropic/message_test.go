@@ -1,16 +1,22 @@
 package anthropic
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMessagesService_Create(t *testing.T) {
 	// Create a mock server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := newTestServer(t, func(r *http.Request) testResponse {
 		// Check request method
 		if r.Method != "POST" {
 			t.Errorf("Expected 'POST' request, got '%s'", r.Method)
@@ -31,11 +37,8 @@ func TestMessagesService_Create(t *testing.T) {
 		}
 
 		// Send response
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(response); err != nil {
-			return
-		}
-	}))
+		return testResponse{value: response}
+	})
 	defer server.Close()
 
 	// Create client with mock server URL
@@ -76,7 +79,7 @@ func TestMessagesService_Create(t *testing.T) {
 
 func TestMessagesService_CreateStreaming(t *testing.T) {
 	// Create a mock server
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := newTestServer(t, func(r *http.Request) testResponse {
 		// Check request method and headers
 		if r.Method != "POST" {
 			t.Errorf("Expected 'POST' request, got '%s'", r.Method)
@@ -86,13 +89,6 @@ func TestMessagesService_CreateStreaming(t *testing.T) {
 		}
 
 		// Send streaming response
-		flusher, ok := w.(http.Flusher)
-		if !ok {
-			t.Fatal("Expected http.ResponseWriter to be an http.Flusher")
-		}
-		w.Header().Set("Content-Type", "text/event-stream")
-		w.WriteHeader(http.StatusOK)
-
 		events := []string{
 			`{"type":"message_start","message":{"id":"msg_123","role":"assistant","model":"claude-3-sonnet-20240229","usage":{"input_tokens":10}}}`,
 			`{"type":"content_block_start","index":0,"content_block":{"type":"text"}}`,
@@ -102,15 +98,8 @@ func TestMessagesService_CreateStreaming(t *testing.T) {
 			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":20}}`,
 			`{"type":"message_stop"}`,
 		}
-
-		for _, event := range events {
-			_, err := w.Write([]byte("data: " + event + "\n\n"))
-			if err != nil {
-				t.Fatalf("Failed to write event: %v", err)
-			}
-			flusher.Flush()
-		}
-	}))
+		return testResponse{events: events}
+	})
 	defer server.Close()
 
 	// Create client with mock server URL
@@ -155,7 +144,7 @@ func TestMessagesService_CreateStreaming(t *testing.T) {
 }
 
 func TestMessagesService_CreateWithTools(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	server := newTestServer(t, func(r *http.Request) testResponse {
 		if r.Method != "POST" {
 			t.Errorf("Expected 'POST' request, got '%s'", r.Method)
 		}
@@ -196,11 +185,8 @@ func TestMessagesService_CreateWithTools(t *testing.T) {
 			},
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		if err := json.NewEncoder(w).Encode(response); err != nil {
-			return
-		}
-	}))
+		return testResponse{value: response}
+	})
 	defer server.Close()
 
 	client, _ := NewClient(
@@ -267,3 +253,704 @@ func TestMessagesService_CreateWithTools(t *testing.T) {
 		t.Errorf("Expected ticker '^GSPC', got '%s'", input["ticker"])
 	}
 }
+
+func TestMessagesService_CreateWithModelFallback(t *testing.T) {
+	var requestedModels []string
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		var requestBody MessageParams
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		requestedModels = append(requestedModels, requestBody.Model)
+
+		if requestBody.Model == string(ModelOpus) {
+			return testResponse{status: StatusOverloaded, body: `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`}
+		}
+		if requestBody.MaxTokens != 1024 {
+			t.Errorf("Expected fallback request to preserve max_tokens 1024, got %d", requestBody.MaxTokens)
+		}
+		return testResponse{value: Message{ID: "msg_123", Role: "assistant", Model: requestBody.Model}}
+	})
+	defer server.Close()
+
+	client, _ := NewClient(
+		WithAPIKey("test-key"),
+		WithBaseURL(server.URL),
+		WithModelFallback(ModelOpus, ModelSonnet, ModelHaiku),
+	)
+
+	params := &MessageParams{
+		Model:     string(ModelOpus),
+		MaxTokens: 1024,
+		Messages: []MessageParam{
+			{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Hello"}}},
+		},
+	}
+
+	message, err := client.Messages().Create(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if message.Model != string(ModelSonnet) {
+		t.Errorf("Expected response served by %s, got %s", ModelSonnet, message.Model)
+	}
+	expected := []string{string(ModelOpus), string(ModelSonnet)}
+	if len(requestedModels) != len(expected) || requestedModels[0] != expected[0] || requestedModels[1] != expected[1] {
+		t.Errorf("Expected requested models %v, got %v", expected, requestedModels)
+	}
+	if params.Model != string(ModelOpus) {
+		t.Errorf("Expected caller params to be left untouched, got model %s", params.Model)
+	}
+}
+
+func TestMessagesService_CreateWithModelFallbackNonRetryable(t *testing.T) {
+	requests := 0
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		requests++
+		return testResponse{status: http.StatusBadRequest, body: `{"type":"error","error":{"type":"invalid_request_error","message":"bad request"}}`}
+	})
+	defer server.Close()
+
+	client, _ := NewClient(
+		WithAPIKey("test-key"),
+		WithBaseURL(server.URL),
+		WithModelFallback(ModelHaiku),
+	)
+
+	_, err := client.Messages().Create(context.Background(), &MessageParams{Model: string(ModelOpus)})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected *APIError, got %v", err)
+	}
+	if apiErr.Type != ErrorTypeInvalidRequest {
+		t.Errorf("Expected error type %s, got %s", ErrorTypeInvalidRequest, apiErr.Type)
+	}
+	if requests != 1 {
+		t.Errorf("Expected no fallback for invalid requests, got %d requests", requests)
+	}
+}
+
+func TestMessageToParam(t *testing.T) {
+	message := &Message{
+		ID:   "msg_123",
+		Role: "assistant",
+		Content: []ContentBlock{
+			{Type: "thinking", Thinking: "Let me check the price.", Signature: "sig_abc"},
+			{Type: "text", Text: "I'll look that up."},
+			{
+				Type: "tool_use",
+				ToolCall: &ToolCall{
+					ID:    "call_123",
+					Type:  "tool_use",
+					Name:  "get_stock_price",
+					Input: json.RawMessage(`{"ticker":"^GSPC"}`),
+				},
+			},
+		},
+	}
+
+	param := message.ToParam()
+	if param.Role != "assistant" {
+		t.Errorf("Expected role 'assistant', got '%s'", param.Role)
+	}
+	if !reflect.DeepEqual(param.Content, message.Content) {
+		t.Errorf("Expected content %+v, got %+v", message.Content, param.Content)
+	}
+	if param.Content[0].Signature != "sig_abc" {
+		t.Errorf("Expected thinking signature to be preserved, got '%s'", param.Content[0].Signature)
+	}
+
+	// The param must not alias the original message.
+	param.Content[2].ToolCall.Name = "changed"
+	if message.Content[2].ToolCall.Name != "get_stock_price" {
+		t.Errorf("Expected ToParam to copy tool calls, original was modified")
+	}
+}
+
+func TestWithCallTimeout(t *testing.T) {
+	testCases := []struct {
+		name      string
+		streaming bool
+	}{
+		{name: "Non-streaming", streaming: false},
+		{name: "Streaming", streaming: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			release := make(chan struct{})
+			server := newTestServer(t, func(r *http.Request) testResponse {
+				if tc.streaming {
+					return testResponse{events: helloStreamEvents[:1], stall: release}
+				}
+				return testResponse{stall: release}
+			})
+			defer server.Close()
+			defer close(release)
+
+			client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+			params := &MessageParams{Model: string(ModelSonnet)}
+			if tc.streaming {
+				params.StreamFunc = func(ctx context.Context, chunk []byte) error { return nil }
+			}
+
+			ctx, cancel := WithCallTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			_, err := client.Messages().Create(ctx, params)
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Expected call to stop near the deadline, took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestMessageUnmarshalStopSequence(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		body                 string
+		expectedStopReason   string
+		expectedStopSequence string
+	}{
+		{
+			name:                 "Stop sequence set",
+			body:                 `{"id":"msg_123","type":"message","role":"assistant","content":[{"type":"text","text":"1, 2, 3"}],"stop_reason":"stop_sequence","stop_sequence":"END"}`,
+			expectedStopReason:   "stop_sequence",
+			expectedStopSequence: "END",
+		},
+		{
+			name:                 "Stop sequence null",
+			body:                 `{"id":"msg_123","type":"message","role":"assistant","content":[{"type":"text","text":"1, 2, 3"}],"stop_reason":"end_turn","stop_sequence":null}`,
+			expectedStopReason:   "end_turn",
+			expectedStopSequence: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, respondWith(testResponse{body: tc.body}))
+			defer server.Close()
+
+			client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+			message, err := client.Messages().Create(context.Background(), &MessageParams{Model: string(ModelSonnet)})
+			if err != nil {
+				t.Fatalf("Failed to create message: %v", err)
+			}
+			if message.StopReason != tc.expectedStopReason {
+				t.Errorf("Expected stop reason %q, got %q", tc.expectedStopReason, message.StopReason)
+			}
+			if message.StopSequence != tc.expectedStopSequence {
+				t.Errorf("Expected stop sequence %q, got %q", tc.expectedStopSequence, message.StopSequence)
+			}
+		})
+	}
+}
+
+func TestMessagesService_Complete(t *testing.T) {
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		var requestBody MessageParams
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		if requestBody.Model != string(ModelHaiku) || requestBody.MaxTokens != 256 {
+			t.Errorf("Unexpected request params: %+v", requestBody)
+		}
+		if len(requestBody.Messages) != 1 || requestBody.Messages[0].Role != "user" || requestBody.Messages[0].Content[0].Text != "What's the capital of France?" {
+			t.Errorf("Unexpected request messages: %+v", requestBody.Messages)
+		}
+
+		return testResponse{value: Message{
+			ID:   "msg_123",
+			Role: "assistant",
+			Content: []ContentBlock{
+				{Type: "text", Text: "The capital of France "},
+				{Type: "text", Text: "is Paris."},
+			},
+		}}
+	})
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	text, err := client.Messages().Complete(context.Background(), ModelHaiku, "What's the capital of France?", 256)
+	if err != nil {
+		t.Fatalf("Failed to complete: %v", err)
+	}
+	if text != "The capital of France is Paris." {
+		t.Errorf("Unexpected completion %q", text)
+	}
+}
+
+func TestMessagesService_CreateStreamingInterleavedThinking(t *testing.T) {
+	turns := [][]string{
+		{
+			`{"type":"message_start","message":{"id":"msg_1","role":"assistant","model":"claude-3-sonnet-20240229","usage":{"input_tokens":10}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"I need the current "}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"price."}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig_1"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_stock_price","input":{}}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"ticker\":"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":" \"^GSPC\"}"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":30}}`,
+			`{"type":"message_stop"}`,
+		},
+		{
+			`{"type":"message_start","message":{"id":"msg_2","role":"assistant","model":"claude-3-sonnet-20240229","usage":{"input_tokens":50}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"The tool returned 4,000."}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig_2"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"The S&P 500 is at 4,000."}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":20}}`,
+			`{"type":"message_stop"}`,
+		},
+	}
+
+	var requests []MessageParams
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		var requestBody MessageParams
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		requests = append(requests, requestBody)
+		return testResponse{events: turns[len(requests)-1]}
+	})
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	streamFunc := func(ctx context.Context, chunk []byte) error { return nil }
+	params := &MessageParams{
+		Model: string(ModelSonnet),
+		Messages: []MessageParam{
+			{Role: "user", Content: []ContentBlock{{Type: "text", Text: "What's the S&P 500 at today?"}}},
+		},
+		StreamFunc: streamFunc,
+	}
+
+	first, err := client.Messages().Create(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Failed to create first message: %v", err)
+	}
+	if len(first.Content) != 2 || first.Content[0].Type != "thinking" || first.Content[1].Type != "tool_use" {
+		t.Fatalf("Expected thinking then tool_use, got %+v", first.Content)
+	}
+	if first.Content[0].Thinking != "I need the current price." || first.Content[0].Signature != "sig_1" {
+		t.Errorf("Unexpected thinking block: %+v", first.Content[0])
+	}
+	var input map[string]string
+	if err := json.Unmarshal(first.Content[1].ToolCall.Input, &input); err != nil || input["ticker"] != "^GSPC" {
+		t.Errorf("Expected assembled tool input {\"ticker\":\"^GSPC\"}, got %s (%v)", string(first.Content[1].ToolCall.Input), err)
+	}
+
+	params.Messages = append(params.Messages,
+		first.ToParam(),
+		MessageParam{Role: "user", Content: []ContentBlock{{
+			Type:       "tool_result",
+			ToolOutput: &ToolOutput{ToolCallID: "toolu_1", Output: "4,000.00"},
+		}}},
+	)
+	if err := params.Validate(); err != nil {
+		t.Fatalf("Expected the reconstructed conversation to be valid, got %v", err)
+	}
+
+	second, err := client.Messages().Create(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Failed to create second message: %v", err)
+	}
+	if len(second.Content) != 2 || second.Content[0].Type != "thinking" || second.Content[1].Type != "text" {
+		t.Fatalf("Expected thinking then text, got %+v", second.Content)
+	}
+	if second.Content[0].Signature != "sig_2" || second.Content[1].Text != "The S&P 500 is at 4,000." {
+		t.Errorf("Unexpected second turn content: %+v", second.Content)
+	}
+
+	params.Messages = append(params.Messages, second.ToParam())
+	if err := params.Validate(); err != nil {
+		t.Errorf("Expected the full conversation to be valid, got %v", err)
+	}
+
+	assertParity(t, turns[0], `{"id":"msg_1","role":"assistant","model":"claude-3-sonnet-20240229","content":[{"type":"thinking","thinking":"I need the current price.","signature":"sig_1"},{"type":"tool_use","id":"toolu_1","name":"get_stock_price","input":{"ticker":"^GSPC"}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":30}}`)
+	assertParity(t, turns[1], `{"id":"msg_2","role":"assistant","model":"claude-3-sonnet-20240229","content":[{"type":"thinking","thinking":"The tool returned 4,000.","signature":"sig_2"},{"type":"text","text":"The S&P 500 is at 4,000."}],"stop_reason":"end_turn","usage":{"input_tokens":50,"output_tokens":20}}`)
+
+	sent := requests[1].Messages[1]
+	if sent.Role != "assistant" || len(sent.Content) != 2 || sent.Content[0].Signature != "sig_1" || sent.Content[1].ToolCall == nil {
+		t.Errorf("Expected the follow-up request to replay thinking with its signature before tool_use, got %+v", sent)
+	}
+}
+
+func TestValidateThinkingBlockOrdering(t *testing.T) {
+	toolUse := ContentBlock{Type: "tool_use", ToolCall: &ToolCall{ID: "toolu_1", Name: "lookup", Input: json.RawMessage(`{}`)}}
+	testCases := []struct {
+		name    string
+		content []ContentBlock
+		valid   bool
+	}{
+		{name: "Thinking before tool use", content: []ContentBlock{{Type: "thinking", Thinking: "hmm", Signature: "sig"}, toolUse}, valid: true},
+		{name: "Redacted thinking before tool use", content: []ContentBlock{{Type: "redacted_thinking", Data: "enc"}, toolUse}, valid: true},
+		{name: "Tool use before thinking", content: []ContentBlock{toolUse, {Type: "thinking", Thinking: "hmm", Signature: "sig"}}, valid: false},
+		{name: "Missing signature", content: []ContentBlock{{Type: "thinking", Thinking: "hmm"}, {Type: "text", Text: "hi"}}, valid: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params := &MessageParams{Messages: []MessageParam{
+				{Role: "user", Content: []ContentBlock{{Type: "text", Text: "hi"}}},
+				{Role: "assistant", Content: tc.content},
+			}}
+			if err := params.Validate(); (err == nil) != tc.valid {
+				t.Errorf("Expected valid=%v, got %v", tc.valid, err)
+			}
+		})
+	}
+}
+
+func TestRequestBetasMaxTokens(t *testing.T) {
+	testCases := []struct {
+		name      string
+		model     ModelID
+		maxTokens int
+		expected  []string
+	}{
+		{name: "Claude 3 Sonnet has no max tokens beta", model: ModelSonnet, maxTokens: 8192},
+		{name: "Claude 3 Sonnet default max tokens", model: ModelSonnet, maxTokens: 4096},
+		{name: "Claude 3.5 Sonnet above default limit", model: ModelSonnet35, maxTokens: 8192, expected: []string{"max-tokens-3-5-sonnet-2024-07-15"}},
+		{name: "Claude 3.5 Sonnet at default limit", model: ModelSonnet35, maxTokens: 4096},
+		{name: "Claude 3.7 Sonnet extended output", model: ModelSonnet37, maxTokens: 128000, expected: []string{"output-128k-2025-02-19"}},
+		{name: "Claude 3.7 Sonnet standard output", model: ModelSonnet37, maxTokens: 64000},
+		{name: "Model without beta", model: ModelHaiku, maxTokens: 8192},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			betas := requestBetas(&MessageParams{Model: string(tc.model), MaxTokens: tc.maxTokens})
+			if !reflect.DeepEqual(betas, tc.expected) {
+				t.Errorf("Expected betas %v, got %v", tc.expected, betas)
+			}
+		})
+	}
+}
+
+func TestMessagesService_CreateBetaFeatures(t *testing.T) {
+	var header string
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		header = r.Header.Get("anthropic-beta")
+		return testResponse{value: Message{ID: "msg_123", Role: "assistant"}}
+	})
+	defer server.Close()
+
+	testCases := []struct {
+		name         string
+		clientBetas  []string
+		requestBetas []string
+		maxTokens    int
+		expected     string
+	}{
+		{name: "No betas", expected: ""},
+		{name: "Client only", clientBetas: []string{"pdfs-2024-09-25"}, expected: "pdfs-2024-09-25"},
+		{name: "Request only", requestBetas: []string{"interleaved-thinking-2025-05-14"}, expected: "interleaved-thinking-2025-05-14"},
+		{
+			name:         "Client and request combined",
+			clientBetas:  []string{"pdfs-2024-09-25"},
+			requestBetas: []string{"interleaved-thinking-2025-05-14"},
+			expected:     "pdfs-2024-09-25,interleaved-thinking-2025-05-14",
+		},
+		{
+			name:         "Duplicates removed",
+			clientBetas:  []string{"pdfs-2024-09-25", "max-tokens-3-5-sonnet-2024-07-15"},
+			requestBetas: []string{"pdfs-2024-09-25", " "},
+			maxTokens:    8192,
+			expected:     "pdfs-2024-09-25,max-tokens-3-5-sonnet-2024-07-15",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL), WithBetaFeatures(tc.clientBetas...))
+			params := &MessageParams{Model: string(ModelSonnet), MaxTokens: tc.maxTokens, BetaFeatures: tc.requestBetas}
+			if _, err := client.Messages().Create(context.Background(), params); err != nil {
+				t.Fatalf("Failed to create message: %v", err)
+			}
+			if header != tc.expected {
+				t.Errorf("Expected anthropic-beta %q, got %q", tc.expected, header)
+			}
+		})
+	}
+}
+
+func TestMessagesService_CreateStreamingCancelReturnsPartial(t *testing.T) {
+	server := newTestServer(t, respondWith(testResponse{events: helloStreamEvents[:4], stall: make(chan struct{})}))
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var streamed string
+	params := &MessageParams{
+		Model: string(ModelSonnet),
+		StreamFunc: func(ctx context.Context, chunk []byte) error {
+			streamed += string(chunk)
+			if streamed == "Hello, world!" {
+				cancel()
+			}
+			return nil
+		},
+	}
+
+	message, err := client.Messages().Create(ctx, params)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected an error wrapping context.Canceled, got %v", err)
+	}
+	if message == nil {
+		t.Fatal("Expected the partial message to be returned")
+	}
+	if message.ID != "msg_123" || message.Text() != "Hello, world!" {
+		t.Errorf("Expected the partial message msg_123 with text %q, got %+v", "Hello, world!", message)
+	}
+}
+
+func TestMessagesService_CreateStreamingCallbackCancelReturnsPartial(t *testing.T) {
+	server := newTestServer(t, respondWith(testResponse{events: helloStreamEvents}))
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The callback stops the stream itself, as StreamText does once ctx is done.
+	params := &MessageParams{
+		Model: string(ModelSonnet),
+		StreamFunc: func(ctx context.Context, chunk []byte) error {
+			cancel()
+			return ctx.Err()
+		},
+	}
+
+	message, err := client.Messages().Create(ctx, params)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected an error wrapping context.Canceled, got %v", err)
+	}
+	if message == nil {
+		t.Fatal("Expected the partial message to be returned")
+	}
+	if message.ID != "msg_123" || message.Text() != "Hello" {
+		t.Errorf("Expected the partial message msg_123 with text %q, got %+v", "Hello", message)
+	}
+}
+
+func TestMessagesService_CreateFineGrainedToolStreaming(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_123","role":"assistant","usage":{"input_tokens":10}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"search","input":{}}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"query\": \"hel"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"lo wor"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"max_tokens"},"usage":{"output_tokens":5}}`,
+		`{"type":"message_stop"}`,
+	}
+	var header string
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		header = r.Header.Get("anthropic-beta")
+		return testResponse{events: events}
+	})
+	defer server.Close()
+
+	var fragments []string
+	params := &MessageParams{
+		Model: string(ModelSonnet),
+		ToolInputDeltaFunc: func(ctx context.Context, toolUseID string, fragment []byte) error {
+			if toolUseID != "toolu_1" {
+				t.Errorf("Expected fragments for toolu_1, got %s", toolUseID)
+			}
+			fragments = append(fragments, string(fragment))
+			return nil
+		},
+	}
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL), WithFineGrainedToolStreaming())
+	message, err := client.Messages().Create(context.Background(), params)
+	if err != nil {
+		t.Fatalf("Expected partial tool input to be accepted, got %v", err)
+	}
+	if header != BetaFineGrainedToolStreaming {
+		t.Errorf("Expected anthropic-beta %q, got %q", BetaFineGrainedToolStreaming, header)
+	}
+	if !reflect.DeepEqual(fragments, []string{`{"query": "hel`, `lo wor`}) {
+		t.Errorf("Unexpected fragments: %q", fragments)
+	}
+	if input := string(message.Content[0].ToolCall.Input); input != `{"query": "hello wor` {
+		t.Errorf("Expected the partial input to be kept, got %s", input)
+	}
+
+	fragments = nil
+	client, _ = NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	_, err = client.Messages().Create(context.Background(), params)
+	if !errors.Is(err, ErrIncompleteToolInput) {
+		t.Errorf("Expected ErrIncompleteToolInput without the beta, got %v", err)
+	}
+	if len(fragments) != 2 {
+		t.Errorf("Expected 2 fragments without the beta, got %d", len(fragments))
+	}
+}
+
+func TestMessagesService_CreateBlockCompleteFunc(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_123","role":"assistant","usage":{"input_tokens":10}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Let me search."}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"search","input":{}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"query\": "}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"go\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":5}}`,
+		`{"type":"message_stop"}`,
+	}
+	server := newTestServer(t, respondWith(testResponse{events: events}))
+	defer server.Close()
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	var indexes []int
+	var blocks []ContentBlock
+	params := &MessageParams{
+		Model: string(ModelSonnet),
+		BlockCompleteFunc: func(ctx context.Context, index int, block ContentBlock) error {
+			indexes = append(indexes, index)
+			blocks = append(blocks, block)
+			return nil
+		},
+	}
+	if !params.IsStreaming() {
+		t.Fatal("Expected BlockCompleteFunc to enable streaming")
+	}
+	if _, err := client.Messages().Create(context.Background(), params); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(indexes, []int{0, 1}) {
+		t.Fatalf("Expected blocks 0 and 1 to complete, got %v", indexes)
+	}
+	if blocks[0].Type != "thinking" || blocks[0].Thinking != "Let me search." {
+		t.Errorf("Expected the completed thinking block, got %+v", blocks[0])
+	}
+	if blocks[1].ToolCall == nil || string(blocks[1].ToolCall.Input) != `{"query": "go"}` {
+		t.Errorf("Expected the completed tool call input, got %+v", blocks[1].ToolCall)
+	}
+
+	errStop := errors.New("stop")
+	params.BlockCompleteFunc = func(ctx context.Context, index int, block ContentBlock) error {
+		return errStop
+	}
+	if _, err := client.Messages().Create(context.Background(), params); !errors.Is(err, errStop) {
+		t.Errorf("Expected the BlockCompleteFunc error, got %v", err)
+	}
+}
+
+func TestCreateMissingContentType(t *testing.T) {
+	testCases := []struct {
+		name       string
+		body       string
+		streamFunc StreamFunc
+		expected   string
+	}{
+		{
+			name:     "Non-streaming",
+			body:     `{"id":"msg_123","role":"assistant","content":[{"type":"text","text":"Hello, world!"}]}`,
+			expected: "decoding it as JSON",
+		},
+		{
+			name:       "Streaming",
+			body:       "data: " + strings.Join(helloStreamEvents, "\n\ndata: ") + "\n\n",
+			streamFunc: func(ctx context.Context, chunk []byte) error { return nil },
+			expected:   "decoding it as an event stream",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// A nil value stops the server from sniffing a Content-Type.
+			server := newTestServer(t, respondWith(testResponse{header: http.Header{"Content-Type": nil}, body: tc.body}))
+			defer server.Close()
+
+			var buf bytes.Buffer
+			client, _ := NewClient(
+				WithAPIKey("test-key"),
+				WithBaseURL(server.URL),
+				WithSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))),
+			)
+			message, err := client.Messages().Create(context.Background(), &MessageParams{Model: string(ModelSonnet), StreamFunc: tc.streamFunc})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if message.Text() != "Hello, world!" {
+				t.Errorf("Expected text %q, got %q", "Hello, world!", message.Text())
+			}
+			if !strings.Contains(buf.String(), tc.expected) {
+				t.Errorf("Expected a warning containing %q, got log %s", tc.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestMessageThinkingText(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_123","role":"assistant","usage":{"input_tokens":10}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"The user greets me. "}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"I should greet back."}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"redacted_thinking","data":"encrypted"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"content_block_start","index":2,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"Hello!"}}`,
+		`{"type":"content_block_stop","index":2}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":20}}`,
+		`{"type":"message_stop"}`,
+	}
+	body := `{"id":"msg_123","role":"assistant","content":[` +
+		`{"type":"thinking","thinking":"The user greets me. I should greet back.","signature":"sig"},` +
+		`{"type":"redacted_thinking","data":"encrypted"},` +
+		`{"type":"text","text":"Hello!"}]}`
+
+	testCases := []struct {
+		name      string
+		streaming bool
+	}{
+		{name: "Non-streaming"},
+		{name: "Streaming", streaming: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var server *httptest.Server
+			if tc.streaming {
+				server = newTestServer(t, respondWith(testResponse{events: events}))
+			} else {
+				server = newTestServer(t, respondWith(testResponse{body: body}))
+			}
+			defer server.Close()
+			client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+			params := &MessageParams{Model: string(ModelSonnet37)}
+			if tc.streaming {
+				params.StreamFunc = func(ctx context.Context, chunk []byte) error { return nil }
+			}
+			message, err := client.Messages().Create(context.Background(), params)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if message.Text() != "Hello!" {
+				t.Errorf("Expected text %q, got %q", "Hello!", message.Text())
+			}
+			if message.ThinkingText() != "The user greets me. I should greet back." {
+				t.Errorf("Expected thinking %q, got %q", "The user greets me. I should greet back.", message.ThinkingText())
+			}
+		})
+	}
+}
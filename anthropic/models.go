@@ -1,8 +1,12 @@
 package anthropic
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -13,6 +17,101 @@ type ContentBlock struct {
 	Source     *Image      `json:"source,omitempty"`
 	ToolCall   *ToolCall   `json:"tool_call,omitempty"`
 	ToolOutput *ToolOutput `json:"tool_output,omitempty"`
+	FileID     string      `json:"file_id,omitempty"`
+	Thinking   string      `json:"thinking,omitempty"`
+	Signature  string      `json:"signature,omitempty"`
+	// Data holds the encrypted content of a redacted_thinking block.
+	Data string `json:"data,omitempty"`
+	// ToolUseID and Content are set on server tool result blocks, such as
+	// web_search_tool_result, whose content is kept as raw JSON.
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+	// Logprobs holds the log probabilities of the block's tokens, when requested
+	// with MessageParams.Logprobs and returned by the API.
+	Logprobs []TokenLogprob `json:"logprobs,omitempty"`
+}
+
+// TokenLogprob is the log probability of a single generated token.
+type TokenLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	// TopLogprobs lists the most likely alternatives at this position, if returned.
+	TopLogprobs []TokenLogprob `json:"top_logprobs,omitempty"`
+}
+
+// MarshalJSON implements custom JSON marshaling for ContentBlock.
+// Tool use and tool result blocks are written in the API's wire format: tool_use
+// blocks carry their id, name and input at the top level, with a missing input sent
+// as {}; tool_result blocks carry tool_use_id, content and is_error. A tool result's
+// Output is sent as string content, or as a leading text block if the result also
+// has Content blocks.
+func (b ContentBlock) MarshalJSON() ([]byte, error) {
+	type Alias ContentBlock
+	switch {
+	case (b.Type == "tool_use" || b.Type == "server_tool_use") && b.ToolCall != nil:
+		input := b.ToolCall.Input
+		if len(input) == 0 || string(input) == "null" {
+			input = json.RawMessage("{}")
+		}
+		return json.Marshal(struct {
+			Type  string          `json:"type"`
+			ID    string          `json:"id"`
+			Name  string          `json:"name"`
+			Input json.RawMessage `json:"input"`
+		}{Type: b.Type, ID: b.ToolCall.ID, Name: b.ToolCall.Name, Input: input})
+	case b.Type == "tool_result" && b.ToolOutput != nil:
+		var content interface{} = b.ToolOutput.Output
+		if len(b.ToolOutput.Content) > 0 {
+			blocks := make([]ContentBlock, 0, len(b.ToolOutput.Content)+1)
+			if b.ToolOutput.Output != "" {
+				blocks = append(blocks, TextContent(b.ToolOutput.Output))
+			}
+			content = append(blocks, b.ToolOutput.Content...)
+		}
+		return json.Marshal(struct {
+			Type      string      `json:"type"`
+			ToolUseID string      `json:"tool_use_id"`
+			Content   interface{} `json:"content"`
+			IsError   bool        `json:"is_error,omitempty"`
+		}{Type: b.Type, ToolUseID: b.ToolOutput.ToolCallID, Content: content, IsError: b.ToolOutput.IsError})
+	}
+	return json.Marshal(Alias(b))
+}
+
+// UnmarshalJSON implements custom JSON unmarshaling for ContentBlock.
+// Tool use blocks carry their id, name and input at the top level in API
+// responses; these are mapped onto ToolCall. Tool result blocks in the wire format
+// are mapped onto ToolOutput: string content becomes Output, and an array of content
+// blocks becomes Content.
+func (b *ContentBlock) UnmarshalJSON(data []byte) error {
+	type Alias ContentBlock
+	aux := struct {
+		*Alias
+		ID      string          `json:"id"`
+		Name    string          `json:"name"`
+		Input   json.RawMessage `json:"input"`
+		IsError bool            `json:"is_error"`
+	}{Alias: (*Alias)(b)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if b.ToolCall == nil && aux.ID != "" && (b.Type == "tool_use" || b.Type == "server_tool_use") {
+		b.ToolCall = &ToolCall{ID: aux.ID, Type: b.Type, Name: aux.Name, Input: aux.Input}
+	}
+	if b.ToolOutput == nil && b.ToolUseID != "" && b.Type == "tool_result" {
+		output := &ToolOutput{ToolCallID: b.ToolUseID, IsError: aux.IsError}
+		if len(b.Content) > 0 && b.Content[0] == '"' {
+			if err := json.Unmarshal(b.Content, &output.Output); err != nil {
+				return err
+			}
+		} else if len(b.Content) > 0 {
+			if err := json.Unmarshal(b.Content, &output.Content); err != nil {
+				return err
+			}
+		}
+		b.ToolOutput, b.ToolUseID, b.Content = output, "", nil
+	}
+	return nil
 }
 
 // Image represents an image in a content block.
@@ -34,27 +133,267 @@ type Message struct {
 	Usage        Usage          `json:"usage"`
 	CreatedAt    time.Time      `json:"created_at"`
 	Beta         *BetaMetadata  `json:"beta,omitempty"`
+	Container    *Container     `json:"container,omitempty"`
+	// Meta holds metadata about the HTTP response, such as rate limit state. It is
+	// set on messages returned by Create.
+	Meta *ResponseMeta `json:"-"`
+}
+
+// Container identifies the code execution container used by a message.
+type Container struct {
+	ID        string    `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Text returns the concatenated text of all text content blocks in the message.
+// Thinking is excluded; use ThinkingText for it.
+func (m *Message) Text() string {
+	var sb strings.Builder
+	for _, block := range m.Content {
+		if block.Type == "text" {
+			sb.WriteString(block.Text)
+		}
+	}
+	return sb.String()
+}
+
+// ThinkingText returns the concatenated thinking of all thinking content blocks in
+// the message. The content of redacted_thinking blocks is encrypted and not included.
+func (m *Message) ThinkingText() string {
+	var sb strings.Builder
+	for _, block := range m.Content {
+		if block.Type == "thinking" {
+			sb.WriteString(block.Thinking)
+		}
+	}
+	return sb.String()
+}
+
+// FlattenContent renders content blocks as a single human-readable string, for
+// logging or display. Text blocks are rendered verbatim, images as "[image]", and
+// other blocks as a short bracketed summary, one block per line.
+func FlattenContent(blocks []ContentBlock) string {
+	lines := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		switch block.Type {
+		case "text":
+			lines = append(lines, block.Text)
+		case "image":
+			lines = append(lines, "[image]")
+		case "tool_use", "server_tool_use":
+			if block.ToolCall == nil {
+				lines = append(lines, "["+block.Type+"]")
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("[%s: %s(%s)]", block.Type, block.ToolCall.Name, block.ToolCall.Input))
+		case "tool_result":
+			if block.ToolOutput == nil {
+				lines = append(lines, "[tool_result]")
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("[tool_result %s: %s]", block.ToolOutput.ToolCallID, block.ToolOutput.Output))
+		case "thinking", "redacted_thinking":
+			lines = append(lines, "[thinking]")
+		case "file":
+			lines = append(lines, fmt.Sprintf("[file: %s]", block.FileID))
+		default:
+			lines = append(lines, "["+block.Type+"]")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ToParam converts the message into a MessageParam that can be appended to the
+// conversation history of a follow-up request. Content blocks are copied, including
+// thinking blocks and their signatures, which the API requires to be sent back unchanged.
+func (m *Message) ToParam() MessageParam {
+	role := m.Role
+	if role == "" {
+		role = "assistant"
+	}
+	content := make([]ContentBlock, len(m.Content))
+	for i, block := range m.Content {
+		if block.Source != nil {
+			source := *block.Source
+			block.Source = &source
+		}
+		if block.ToolCall != nil {
+			toolCall := *block.ToolCall
+			toolCall.Input = append(json.RawMessage(nil), block.ToolCall.Input...)
+			block.ToolCall = &toolCall
+		}
+		if block.ToolOutput != nil {
+			toolOutput := *block.ToolOutput
+			toolOutput.Content = append([]ContentBlock(nil), block.ToolOutput.Content...)
+			block.ToolOutput = &toolOutput
+		}
+		content[i] = block
+	}
+	return MessageParam{Role: role, Content: content}
 }
 
 // Usage represents the token usage information.
 type Usage struct {
-	InputTokens  int `json:"input_tokens"`
-	OutputTokens int `json:"output_tokens"`
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
 }
 
+// Add returns the sum of u and other across all token fields.
+func (u Usage) Add(other Usage) Usage {
+	return Usage{
+		InputTokens:              u.InputTokens + other.InputTokens,
+		OutputTokens:             u.OutputTokens + other.OutputTokens,
+		CacheCreationInputTokens: u.CacheCreationInputTokens + other.CacheCreationInputTokens,
+		CacheReadInputTokens:     u.CacheReadInputTokens + other.CacheReadInputTokens,
+	}
+}
+
+// Sub returns the difference u - other across all token fields, clamped at zero.
+// It is useful for computing per-turn deltas from accumulated usage.
+func (u Usage) Sub(other Usage) Usage {
+	return Usage{
+		InputTokens:              clampedSub(u.InputTokens, other.InputTokens),
+		OutputTokens:             clampedSub(u.OutputTokens, other.OutputTokens),
+		CacheCreationInputTokens: clampedSub(u.CacheCreationInputTokens, other.CacheCreationInputTokens),
+		CacheReadInputTokens:     clampedSub(u.CacheReadInputTokens, other.CacheReadInputTokens),
+	}
+}
+
+// TotalInputTokens returns all input tokens of the request, including those written
+// to and read from the prompt cache, which the API reports separately from InputTokens.
+func (u Usage) TotalInputTokens() int {
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
+
+// CacheSavings returns the number of input tokens that were read from the prompt
+// cache rather than reprocessed.
+func (u Usage) CacheSavings() int {
+	return u.CacheReadInputTokens
+}
+
+// CacheSavingsPercent returns the percentage, from 0 to 100, of total input tokens
+// that were served from the prompt cache. It returns 0 when there was no input.
+func (u Usage) CacheSavingsPercent() float64 {
+	total := u.TotalInputTokens()
+	if total == 0 {
+		return 0
+	}
+	return float64(u.CacheSavings()) / float64(total) * 100
+}
+
+func clampedSub(a, b int) int {
+	if a < b {
+		return 0
+	}
+	return a - b
+}
+
+// StreamFunc is called with each chunk of streamed content.
+type StreamFunc func(ctx context.Context, chunk []byte) error
+
+// ToolInputDeltaFunc is called with each raw JSON fragment of a streamed tool call's
+// input. Fragments are not valid JSON on their own; they concatenate to the input.
+type ToolInputDeltaFunc func(ctx context.Context, toolUseID string, fragment []byte) error
+
+// BlockCompleteFunc is called with each content block of a streamed response once it
+// is complete, along with its index in the message content.
+type BlockCompleteFunc func(ctx context.Context, index int, block ContentBlock) error
+
+// StreamErrorMode determines how a streaming request reacts to a StreamFunc error.
+type StreamErrorMode int
+
+const (
+	// StreamErrorAbort aborts the stream and returns the StreamFunc error from Create.
+	StreamErrorAbort StreamErrorMode = iota
+	// StreamErrorContinue stops invoking StreamFunc after its first error but keeps
+	// reading the stream, so Create still returns the fully assembled Message.
+	StreamErrorContinue
+)
+
 // MessageParams represents the parameters for creating a message.
 type MessageParams struct {
-	Model         string                              `json:"model"`
-	Messages      []MessageParam                      `json:"messages"`
-	MaxTokens     int                                 `json:"max_tokens,omitempty"`
-	Temperature   float64                             `json:"temperature,omitempty"`
-	TopP          float64                             `json:"top_p,omitempty"`
-	TopK          int                                 `json:"top_k,omitempty"`
-	StopSequences []string                            `json:"stop_sequences,omitempty"`
-	Metadata      map[string]interface{}              `json:"metadata,omitempty"`
-	StreamFunc    func(context.Context, []byte) error `json:"-"`
-	Tools         []Tool                              `json:"tools,omitempty"`
-	ToolChoice    *ToolChoice                         `json:"tool_choice,omitempty"`
+	Model     string         `json:"model"`
+	System    string         `json:"system,omitempty"`
+	Messages  []MessageParam `json:"messages"`
+	MaxTokens int            `json:"max_tokens,omitempty"`
+	// Temperature, TopP and TopK are pointers so that an explicit zero, such as a
+	// temperature of 0 for deterministic output, is sent rather than omitted. Use Ptr
+	// to set them; nil leaves the API's default, or the client's default if set.
+	Temperature   *float64               `json:"temperature,omitempty"`
+	TopP          *float64               `json:"top_p,omitempty"`
+	TopK          *int                   `json:"top_k,omitempty"`
+	StopSequences []string               `json:"stop_sequences,omitempty"`
+	Metadata      map[string]interface{} `json:"metadata,omitempty"`
+	StreamFunc    StreamFunc             `json:"-"`
+	// StreamErrorMode controls what happens when StreamFunc returns an error.
+	// The zero value, StreamErrorAbort, aborts the stream.
+	StreamErrorMode StreamErrorMode `json:"-"`
+	// RawEventFunc, if set, is invoked with the name and raw data of every
+	// server-sent event before it is parsed. Setting it enables streaming.
+	RawEventFunc func(ctx context.Context, eventName string, data []byte) error `json:"-"`
+	// ToolInputDeltaFunc, if set, is invoked with every raw input_json_delta fragment
+	// of a tool call as it is streamed. Setting it enables streaming.
+	ToolInputDeltaFunc ToolInputDeltaFunc `json:"-"`
+	// BlockCompleteFunc, if set, is invoked with each content block when its
+	// content_block_stop event arrives, such as to render a finished tool call or
+	// thinking block as a unit. Setting it enables streaming.
+	BlockCompleteFunc BlockCompleteFunc `json:"-"`
+	Tools             []Tool            `json:"tools,omitempty"`
+	ToolChoice        *ToolChoice       `json:"tool_choice,omitempty"`
+	// OutputSchema requests structured output matching the schema. It is sent as
+	// "output_schema" for forward compatibility; until the API supports it natively,
+	// structured output is obtained by forcing a tool whose InputSchema is the schema.
+	OutputSchema *InputSchema `json:"output_schema,omitempty"`
+	// Extra holds additional top-level request fields that are merged into the
+	// request body verbatim, overriding typed fields with the same key.
+	// It allows adopting new API parameters before they are exposed as typed fields.
+	Extra map[string]interface{} `json:"-"`
+	// BetaFeatures lists beta features enabled for this request only, in addition
+	// to those set on the client with WithBetaFeatures.
+	BetaFeatures []string `json:"-"`
+	// SystemBlocks sets the system prompt as content blocks, which, unlike System,
+	// can carry cache_control. It is mutually exclusive with System.
+	SystemBlocks []SystemBlock `json:"-"`
+	// Thinking enables extended thinking on models that support it.
+	Thinking *ThinkingConfig `json:"thinking,omitempty"`
+	// Logprobs requests token log probabilities, returned in ContentBlock.Logprobs.
+	// The API does not document this parameter yet; it is sent as "logprobs"
+	// through Extra, which takes precedence if it sets the same key.
+	Logprobs bool `json:"-"`
+}
+
+// ThinkingConfig configures extended thinking.
+type ThinkingConfig struct {
+	// Type is "enabled" or "disabled".
+	Type string `json:"type"`
+	// BudgetTokens is the maximum number of tokens the model may use for thinking.
+	BudgetTokens int `json:"budget_tokens,omitempty"`
+}
+
+// SystemBlock is a text block of a system prompt.
+type SystemBlock struct {
+	Type         string             `json:"type"`
+	Text         string             `json:"text"`
+	CacheControl *CacheControlParam `json:"cache_control,omitempty"`
+}
+
+// CacheControlParam marks the prompt prefix ending at a block as cacheable.
+type CacheControlParam struct {
+	Type CacheControl `json:"type"`
+}
+
+// systemPrompt returns the value of the "system" request field: SystemBlocks if set,
+// otherwise System, or nil if there is no system prompt.
+func (p *MessageParams) systemPrompt() interface{} {
+	if len(p.SystemBlocks) > 0 {
+		return p.SystemBlocks
+	}
+	if p.System != "" {
+		return p.System
+	}
+	return nil
 }
 
 type BetaMetadata struct {
@@ -99,27 +438,171 @@ type TextEditorTool struct {
 
 // IsStreaming returns true if the MessageParams is configured for streaming.
 func (p *MessageParams) IsStreaming() bool {
-	return p.StreamFunc != nil
+	return p.StreamFunc != nil || p.RawEventFunc != nil || p.ToolInputDeltaFunc != nil || p.BlockCompleteFunc != nil
 }
 
 // MarshalJSON implements custom JSON marshaling for MessageParams.
 func (p *MessageParams) MarshalJSON() ([]byte, error) {
+	return p.marshal(callOptions{})
+}
+
+// marshal encodes p as a request body, applying the client configuration in opts.
+func (p *MessageParams) marshal(opts callOptions) ([]byte, error) {
 	type Alias MessageParams
-	return json.Marshal(&struct {
+	var stream *bool
+	if p.IsStreaming() || !opts.omitStreamFalse {
+		stream = Ptr(p.IsStreaming())
+	}
+	data, err := json.Marshal(&struct {
 		*Alias
-		Stream bool `json:"stream"`
+		System interface{} `json:"system,omitempty"`
+		Stream *bool       `json:"stream,omitempty"`
 	}{
 		Alias:  (*Alias)(p),
-		Stream: p.IsStreaming(),
+		System: p.systemPrompt(),
+		Stream: stream,
 	})
+	extra := p.extraFields()
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	return mergeJSONFields(data, extra)
 }
 
+// mergeJSONFields sets the given fields on the JSON object data. Fields already in
+// data keep their position and are replaced in place; the others are appended in key
+// order. Existing values are copied verbatim and each field is marshaled on its own,
+// so numbers keep their precision.
+func mergeJSONFields(data []byte, fields map[string]interface{}) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	writeField := func(key string, value json.RawMessage) error {
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+		return nil
+	}
+	marshalField := func(key string) (json.RawMessage, error) {
+		value, err := json.Marshal(fields[key])
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling extra field %q: %w", key, err)
+		}
+		return value, nil
+	}
+
+	written := make(map[string]bool, len(fields))
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := token.(string)
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+		if _, ok := fields[key]; ok {
+			if value, err = marshalField(key); err != nil {
+				return nil, err
+			}
+			written[key] = true
+		}
+		if err := writeField(key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		if !written[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := marshalField(key)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeField(key, value); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// extraFields returns the top-level fields merged into the request body: Extra,
+// plus the experimental parameters that are sent through it.
+func (p *MessageParams) extraFields() map[string]interface{} {
+	if !p.Logprobs {
+		return p.Extra
+	}
+	extra := map[string]interface{}{"logprobs": true}
+	for k, v := range p.Extra {
+		extra[k] = v
+	}
+	return extra
+}
+
+// Role is the author of a message in the conversation history.
+type Role string
+
+// Roles allowed in MessageParams.Messages. The system prompt is not a message; it is
+// set with MessageParams.System.
+const (
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
 // MessageParam represents a single message in the conversation history.
+// Role must be RoleUser or RoleAssistant.
 type MessageParam struct {
 	Role    string         `json:"role"`
 	Content []ContentBlock `json:"content"`
 }
 
+// UnmarshalJSON implements custom JSON unmarshaling for MessageParam.
+// Content may be a plain string, as the API accepts, which is wrapped in a single
+// text block; messages are always marshaled with content blocks.
+func (m *MessageParam) UnmarshalJSON(data []byte) error {
+	type Alias MessageParam
+	aux := struct {
+		*Alias
+		Content json.RawMessage `json:"content"`
+	}{Alias: (*Alias)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	content := aux.Content
+	if len(content) > 0 && content[0] == '"' {
+		var text string
+		if err := json.Unmarshal(content, &text); err != nil {
+			return err
+		}
+		m.Content = []ContentBlock{TextContent(text)}
+		return nil
+	}
+	m.Content = nil
+	if len(content) == 0 {
+		return nil
+	}
+	return json.Unmarshal(content, &m.Content)
+}
+
 // TextBlock is a convenience type for creating text content blocks.
 type TextBlock struct {
 	Type string `json:"type"`
@@ -141,11 +624,46 @@ type Tool struct {
 	Name        string      `json:"name"`
 	Description string      `json:"description"`
 	InputSchema InputSchema `json:"input_schema"`
+	// RawInputSchema, when set, is sent verbatim as the input schema instead of
+	// InputSchema. It accepts a json.RawMessage, a map[string]interface{}, or any
+	// other value that marshals to a JSON Schema object.
+	RawInputSchema interface{} `json:"-"`
+	// ServerTool, when set, is sent verbatim instead of the fields above. It holds
+	// the definition of a server-side tool such as *WebSearchTool.
+	ServerTool interface{} `json:"-"`
+}
+
+// MarshalJSON implements custom JSON marshaling for Tool.
+func (t Tool) MarshalJSON() ([]byte, error) {
+	type Alias Tool
+	if t.ServerTool != nil {
+		return json.Marshal(t.ServerTool)
+	}
+	if t.RawInputSchema == nil {
+		return json.Marshal(Alias(t))
+	}
+	return json.Marshal(&struct {
+		Name        string      `json:"name"`
+		Description string      `json:"description"`
+		InputSchema interface{} `json:"input_schema"`
+	}{
+		Name:        t.Name,
+		Description: t.Description,
+		InputSchema: t.RawInputSchema,
+	})
 }
 
+// InputSchema describes the JSON Schema of a tool input.
+// It covers the commonly used subset of JSON Schema; nested schemas can be expressed
+// by using *InputSchema values in Properties or Items.
 type InputSchema struct {
-	Type       string                 `json:"type"`
-	Properties map[string]interface{} `json:"properties"`
+	Type                 string                 `json:"type"`
+	Description          string                 `json:"description,omitempty"`
+	Properties           map[string]interface{} `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *InputSchema           `json:"items,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
 }
 
 // ToolCall represents a call to a tool made by the model.
@@ -157,9 +675,26 @@ type ToolCall struct {
 }
 
 // ToolOutput represents the output of a tool call.
+// Content carries structured result blocks, such as {"type":"file","file_id":"..."}
+// for files produced by server-side tools.
 type ToolOutput struct {
-	ToolCallID string `json:"tool_call_id"`
-	Output     string `json:"output"`
+	ToolCallID string         `json:"tool_call_id"`
+	Output     string         `json:"output"`
+	Content    []ContentBlock `json:"content,omitempty"`
+	// IsError reports that the tool failed and Output describes the error.
+	IsError bool `json:"is_error,omitempty"`
+}
+
+// FileIDs returns the IDs of all file blocks in the tool output content.
+// The files can be retrieved with FilesService.Download.
+func (o *ToolOutput) FileIDs() []string {
+	var ids []string
+	for _, block := range o.Content {
+		if block.Type == "file" && block.FileID != "" {
+			ids = append(ids, block.FileID)
+		}
+	}
+	return ids
 }
 
 // Error represents an error returned by the API.
@@ -177,10 +712,17 @@ type MessageEvent struct {
 // ModelID represents the available model IDs.
 type ModelID string
 
-// Model represents an Anthropic AI model.
+// Model represents an Anthropic AI model and the features it supports.
 type Model struct {
-	ID   ModelID `json:"id"`
-	Name string  `json:"name"`
+	ID               ModelID `json:"id"`
+	Name             string  `json:"name"`
+	SupportsVision   bool    `json:"supports_vision,omitempty"`
+	SupportsTools    bool    `json:"supports_tools,omitempty"`
+	SupportsThinking bool    `json:"supports_thinking,omitempty"`
+	SupportsPDF      bool    `json:"supports_pdf,omitempty"`
+	// MaxOutputTokens is the largest max_tokens the model accepts, including with any
+	// beta the SDK enables for large values, or 0 if unknown.
+	MaxOutputTokens int `json:"max_output_tokens,omitempty"`
 }
 
 // Constants for available model IDs.
@@ -188,4 +730,27 @@ const (
 	ModelHaiku  ModelID = "claude-3-haiku-20240307"
 	ModelSonnet ModelID = "claude-3-sonnet-20240229"
 	ModelOpus   ModelID = "claude-3-opus-20240229"
+
+	ModelSonnet35 ModelID = "claude-3-5-sonnet-20240620"
+	ModelSonnet37 ModelID = "claude-3-7-sonnet-20250219"
 )
+
+// IsKnownModel reports whether model has a constant in this package.
+func IsKnownModel(model ModelID) bool {
+	_, ok := GetModel(model)
+	return ok
+}
+
+// maxTokensBeta describes the beta feature a model requires to accept a large max_tokens.
+type maxTokensBeta struct {
+	// MinMaxTokens is the smallest max_tokens value that requires the beta.
+	MinMaxTokens int
+	Beta         string
+}
+
+// maxTokensBetas lists, per model, the beta required to raise the output limit.
+// Models without an entry accept their full max_tokens range without a beta.
+var maxTokensBetas = map[ModelID]maxTokensBeta{
+	ModelSonnet35: {MinMaxTokens: 4097, Beta: BetaMaxTokens35Sonnet},
+	ModelSonnet37: {MinMaxTokens: 64001, Beta: BetaOutput128K},
+}
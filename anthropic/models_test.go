@@ -3,6 +3,7 @@ package anthropic
 import (
 	"context"
 	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -51,3 +52,455 @@ func TestMessageParamsMarshalJSON(t *testing.T) {
 		t.Errorf("Expected JSON to contain \"stream\":true, got %s", string(jsonData))
 	}
 }
+
+func TestMessageParamsMarshalJSONOutputSchema(t *testing.T) {
+	params := &MessageParams{
+		Model: string(ModelSonnet),
+		OutputSchema: &InputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"name": map[string]interface{}{"type": "string"},
+			},
+		},
+	}
+
+	jsonData, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("Failed to marshal MessageParams: %v", err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(jsonData, &fields); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v", err)
+	}
+	expected := `{"type":"object","properties":{"name":{"type":"string"}}}`
+	if string(fields["output_schema"]) != expected {
+		t.Errorf("Expected output_schema %s, got %s", expected, string(fields["output_schema"]))
+	}
+}
+
+func TestMessageParamsMarshalJSONExtra(t *testing.T) {
+	params := &MessageParams{
+		Model:     string(ModelSonnet),
+		MaxTokens: 100,
+		Extra: map[string]interface{}{
+			"response_format": map[string]interface{}{"type": "json"},
+			"max_tokens":      200,
+		},
+	}
+
+	jsonData, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("Failed to marshal MessageParams: %v", err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(jsonData, &fields); err != nil {
+		t.Fatalf("Failed to unmarshal JSON: %v", err)
+	}
+	if _, ok := fields["response_format"]; !ok {
+		t.Errorf("Expected extra field response_format in %s", string(jsonData))
+	}
+	if fields["max_tokens"] != float64(200) {
+		t.Errorf("Expected extra field to override max_tokens, got %v", fields["max_tokens"])
+	}
+	if fields["model"] != string(ModelSonnet) {
+		t.Errorf("Expected typed fields to be kept, got model %v", fields["model"])
+	}
+}
+
+func TestMessageParamsMarshalJSONExtraPrecision(t *testing.T) {
+	params := &MessageParams{
+		Model:     string(ModelSonnet),
+		MaxTokens: 100,
+		Metadata:  map[string]interface{}{"trace_id": uint64(9007199254740993)},
+		Messages: []MessageParam{{Role: "assistant", Content: []ContentBlock{
+			ToolUseContent("toolu_1", "lookup", json.RawMessage(`{"id":12345678901234567890}`)),
+		}}},
+		Logprobs: true,
+		Extra:    map[string]interface{}{"seed": uint64(18446744073709551615), "max_tokens": 200},
+	}
+
+	first, err := json.Marshal(params)
+	if err != nil {
+		t.Fatalf("Failed to marshal MessageParams: %v", err)
+	}
+	for _, want := range []string{`"trace_id":9007199254740993`, `"input":{"id":12345678901234567890}`, `"seed":18446744073709551615`} {
+		if !strings.Contains(string(first), want) {
+			t.Errorf("Expected %s to keep %s", first, want)
+		}
+	}
+	// Typed fields keep their position, with overrides in place and other extra
+	// fields appended in key order.
+	for _, order := range [][2]string{{`"model"`, `"max_tokens":200`}, {`"messages"`, `"max_tokens":200`}, {`"stream"`, `"logprobs":true`}, {`"logprobs":true`, `"seed"`}} {
+		if strings.Index(string(first), order[0]) > strings.Index(string(first), order[1]) {
+			t.Errorf("Expected %s before %s in %s", order[0], order[1], first)
+		}
+	}
+
+	second, _ := json.Marshal(params)
+	if string(first) != string(second) {
+		t.Errorf("Expected a stable encoding, got %s and %s", first, second)
+	}
+}
+
+func TestMessageParamsMarshalJSONLogprobs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		params   *MessageParams
+		expected interface{}
+	}{
+		{name: "Disabled", params: &MessageParams{}, expected: nil},
+		{name: "Enabled", params: &MessageParams{Logprobs: true}, expected: true},
+		{
+			name:     "Extra overrides",
+			params:   &MessageParams{Logprobs: true, Extra: map[string]interface{}{"logprobs": map[string]interface{}{"top": float64(3)}}},
+			expected: map[string]interface{}{"top": float64(3)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jsonData, err := json.Marshal(tc.params)
+			if err != nil {
+				t.Fatalf("Failed to marshal MessageParams: %v", err)
+			}
+			var fields map[string]interface{}
+			if err := json.Unmarshal(jsonData, &fields); err != nil {
+				t.Fatalf("Failed to unmarshal JSON: %v", err)
+			}
+			if !reflect.DeepEqual(fields["logprobs"], tc.expected) {
+				t.Errorf("Expected logprobs %v, got %v", tc.expected, fields["logprobs"])
+			}
+		})
+	}
+
+	params := &MessageParams{Logprobs: true}
+	if _, err := json.Marshal(params); err != nil || params.Extra != nil {
+		t.Errorf("Expected Extra to be left unchanged, got %v (error %v)", params.Extra, err)
+	}
+}
+
+func TestContentBlockLogprobs(t *testing.T) {
+	data := `{"type":"text","text":"Yes","logprobs":[{"token":"Yes","logprob":-0.01,"top_logprobs":[{"token":"No","logprob":-4.6}]}]}`
+	var block ContentBlock
+	if err := json.Unmarshal([]byte(data), &block); err != nil {
+		t.Fatalf("Failed to unmarshal ContentBlock: %v", err)
+	}
+	expected := []TokenLogprob{{Token: "Yes", Logprob: -0.01, TopLogprobs: []TokenLogprob{{Token: "No", Logprob: -4.6}}}}
+	if !reflect.DeepEqual(block.Logprobs, expected) {
+		t.Fatalf("Expected logprobs %+v, got %+v", expected, block.Logprobs)
+	}
+
+	marshaled, err := json.Marshal(block)
+	if err != nil {
+		t.Fatalf("Failed to marshal ContentBlock: %v", err)
+	}
+	if !strings.Contains(string(marshaled), `"logprobs":[{"token":"Yes","logprob":-0.01,"top_logprobs":[{"token":"No","logprob":-4.6}]}]`) {
+		t.Errorf("Expected logprobs to round-trip, got %s", marshaled)
+	}
+
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_123","role":"assistant","usage":{"input_tokens":10}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Ye","logprobs":[{"token":"Ye","logprob":-0.5}]}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"s","logprobs":[{"token":"s","logprob":-0.25}]}}`,
+		`{"type":"message_stop"}`,
+	}
+	message, err := ParseMessageStream(context.Background(), strings.NewReader("data: "+strings.Join(events, "\n\ndata: ")+"\n\n"), nil)
+	if err != nil {
+		t.Fatalf("Failed to parse stream: %v", err)
+	}
+	streamed := []TokenLogprob{{Token: "Ye", Logprob: -0.5}, {Token: "s", Logprob: -0.25}}
+	if !reflect.DeepEqual(message.Content[0].Logprobs, streamed) {
+		t.Errorf("Expected streamed logprobs %+v, got %+v", streamed, message.Content[0].Logprobs)
+	}
+}
+
+func TestContentBlockMarshalJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		block    ContentBlock
+		expected string
+	}{
+		{name: "Text", block: TextContent("Hi"), expected: `{"type":"text","text":"Hi"}`},
+		{
+			name:     "Tool use",
+			block:    ToolUseContent("toolu_1", "search", json.RawMessage(`{"q":"go"}`)),
+			expected: `{"type":"tool_use","id":"toolu_1","name":"search","input":{"q":"go"}}`,
+		},
+		{
+			name:     "Tool use without input",
+			block:    ToolUseContent("toolu_1", "now", nil),
+			expected: `{"type":"tool_use","id":"toolu_1","name":"now","input":{}}`,
+		},
+		{
+			name:     "Tool result",
+			block:    ToolResultContent("toolu_1", "42 results", false),
+			expected: `{"type":"tool_result","tool_use_id":"toolu_1","content":"42 results"}`,
+		},
+		{
+			name:     "Tool error",
+			block:    ToolResultContent("toolu_1", "timed out", true),
+			expected: `{"type":"tool_result","tool_use_id":"toolu_1","content":"timed out","is_error":true}`,
+		},
+		{
+			name: "Tool result with content blocks",
+			block: ContentBlock{Type: "tool_result", ToolOutput: &ToolOutput{
+				ToolCallID: "toolu_1",
+				Output:     "wrote report",
+				Content:    []ContentBlock{{Type: "file", FileID: "file_1"}},
+			}},
+			expected: `{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"text","text":"wrote report"},{"type":"file","file_id":"file_1"}]}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(tc.block)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if string(data) != tc.expected {
+				t.Errorf("Expected %s, got %s", tc.expected, data)
+			}
+
+			var decoded ContentBlock
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("Unexpected error decoding %s: %v", data, err)
+			}
+			again, _ := json.Marshal(decoded)
+			if string(again) != tc.expected {
+				t.Errorf("Expected %s after a round trip, got %s", tc.expected, again)
+			}
+		})
+	}
+}
+
+func TestMessageParamUnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected MessageParam
+	}{
+		{
+			name:     "String content",
+			input:    `{"role":"user","content":"Hello, Claude"}`,
+			expected: MessageParam{Role: "user", Content: []ContentBlock{TextContent("Hello, Claude")}},
+		},
+		{
+			name:  "Content blocks",
+			input: `{"role":"assistant","content":[{"type":"text","text":"Hi"},{"type":"tool_use","id":"toolu_1","name":"search","input":{"q":"go"}}]}`,
+			expected: MessageParam{Role: "assistant", Content: []ContentBlock{
+				TextContent("Hi"),
+				{Type: "tool_use", ToolCall: &ToolCall{ID: "toolu_1", Type: "tool_use", Name: "search", Input: json.RawMessage(`{"q":"go"}`)}},
+			}},
+		},
+		{
+			name:     "Null content",
+			input:    `{"role":"user","content":null}`,
+			expected: MessageParam{Role: "user"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var param MessageParam
+			if err := json.Unmarshal([]byte(tc.input), &param); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(param, tc.expected) {
+				t.Errorf("Expected %+v, got %+v", tc.expected, param)
+			}
+		})
+	}
+
+	var param MessageParam
+	if err := json.Unmarshal([]byte(`{"role":"user","content":42}`), &param); err == nil {
+		t.Error("Expected an error for content that is neither a string nor blocks")
+	}
+}
+
+func TestUsageAddSub(t *testing.T) {
+	previous := Usage{InputTokens: 100, OutputTokens: 50, CacheCreationInputTokens: 20, CacheReadInputTokens: 10}
+	turn := Usage{InputTokens: 30, OutputTokens: 15, CacheCreationInputTokens: 5, CacheReadInputTokens: 40}
+
+	total := previous.Add(turn)
+	expectedTotal := Usage{InputTokens: 130, OutputTokens: 65, CacheCreationInputTokens: 25, CacheReadInputTokens: 50}
+	if total != expectedTotal {
+		t.Errorf("Expected total %+v, got %+v", expectedTotal, total)
+	}
+
+	if delta := total.Sub(previous); delta != turn {
+		t.Errorf("Expected delta %+v, got %+v", turn, delta)
+	}
+
+	clamped := previous.Sub(total)
+	if clamped != (Usage{}) {
+		t.Errorf("Expected negative differences to clamp to zero, got %+v", clamped)
+	}
+
+	partial := Usage{InputTokens: 10, CacheReadInputTokens: 60}.Sub(Usage{InputTokens: 4, CacheReadInputTokens: 80})
+	expectedPartial := Usage{InputTokens: 6}
+	if partial != expectedPartial {
+		t.Errorf("Expected %+v, got %+v", expectedPartial, partial)
+	}
+}
+
+func TestUsageUnmarshalCacheFields(t *testing.T) {
+	var usage Usage
+	data := `{"input_tokens":10,"output_tokens":5,"cache_creation_input_tokens":100,"cache_read_input_tokens":200}`
+	if err := json.Unmarshal([]byte(data), &usage); err != nil {
+		t.Fatalf("Failed to unmarshal Usage: %v", err)
+	}
+	expected := Usage{InputTokens: 10, OutputTokens: 5, CacheCreationInputTokens: 100, CacheReadInputTokens: 200}
+	if usage != expected {
+		t.Errorf("Expected %+v, got %+v", expected, usage)
+	}
+}
+
+func TestInputSchemaMarshalJSON(t *testing.T) {
+	additionalProperties := false
+	schema := InputSchema{
+		Type: "object",
+		Properties: map[string]interface{}{
+			"unit": &InputSchema{
+				Type:        "string",
+				Description: "Temperature unit",
+				Enum:        []interface{}{"celsius", "fahrenheit"},
+			},
+			"locations": &InputSchema{
+				Type: "array",
+				Items: &InputSchema{
+					Type: "object",
+					Properties: map[string]interface{}{
+						"city": &InputSchema{Type: "string"},
+					},
+					Required: []string{"city"},
+				},
+			},
+		},
+		Required:             []string{"locations"},
+		AdditionalProperties: &additionalProperties,
+	}
+
+	jsonData, err := json.Marshal(schema)
+	if err != nil {
+		t.Fatalf("Failed to marshal InputSchema: %v", err)
+	}
+
+	expected := `{"type":"object","properties":{"locations":{"type":"array","items":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}},"unit":{"type":"string","description":"Temperature unit","enum":["celsius","fahrenheit"]}},"required":["locations"],"additionalProperties":false}`
+	if string(jsonData) != expected {
+		t.Errorf("Expected JSON %s, got %s", expected, string(jsonData))
+	}
+
+	var decoded InputSchema
+	if err := json.Unmarshal(jsonData, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal InputSchema: %v", err)
+	}
+	if decoded.Type != "object" || len(decoded.Required) != 1 || decoded.AdditionalProperties == nil || *decoded.AdditionalProperties {
+		t.Errorf("Unexpected decoded schema: %+v", decoded)
+	}
+}
+
+func TestToolMarshalJSONRawInputSchema(t *testing.T) {
+	testCases := []struct {
+		name     string
+		tool     Tool
+		expected string
+	}{
+		{
+			name: "Typed schema",
+			tool: Tool{
+				Name:        "get_weather",
+				Description: "Get the weather",
+				InputSchema: InputSchema{Type: "object"},
+			},
+			expected: `{"name":"get_weather","description":"Get the weather","input_schema":{"type":"object"}}`,
+		},
+		{
+			name: "Raw JSON schema",
+			tool: Tool{
+				Name:           "get_weather",
+				Description:    "Get the weather",
+				InputSchema:    InputSchema{Type: "ignored"},
+				RawInputSchema: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}`),
+			},
+			expected: `{"name":"get_weather","description":"Get the weather","input_schema":{"type":"object","properties":{"city":{"type":"string"}},"required":["city"]}}`,
+		},
+		{
+			name: "Map schema",
+			tool: Tool{
+				Name:        "get_weather",
+				Description: "Get the weather",
+				RawInputSchema: map[string]interface{}{
+					"type":     "object",
+					"required": []string{"city"},
+				},
+			},
+			expected: `{"name":"get_weather","description":"Get the weather","input_schema":{"required":["city"],"type":"object"}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jsonData, err := json.Marshal([]Tool{tc.tool})
+			if err != nil {
+				t.Fatalf("Failed to marshal Tool: %v", err)
+			}
+			if string(jsonData) != "["+tc.expected+"]" {
+				t.Errorf("Expected JSON [%s], got %s", tc.expected, string(jsonData))
+			}
+		})
+	}
+}
+
+func TestUsageCacheSavings(t *testing.T) {
+	testCases := []struct {
+		name            string
+		usage           Usage
+		expectedSavings int
+		expectedPercent float64
+	}{
+		{name: "No input", usage: Usage{}, expectedSavings: 0, expectedPercent: 0},
+		{name: "No cache", usage: Usage{InputTokens: 100}, expectedSavings: 0, expectedPercent: 0},
+		{name: "Cache write only", usage: Usage{InputTokens: 10, CacheCreationInputTokens: 90}, expectedSavings: 0, expectedPercent: 0},
+		{name: "Cache read", usage: Usage{InputTokens: 10, CacheCreationInputTokens: 15, CacheReadInputTokens: 75}, expectedSavings: 75, expectedPercent: 75},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.usage.CacheSavings(); got != tc.expectedSavings {
+				t.Errorf("Expected savings %d, got %d", tc.expectedSavings, got)
+			}
+			if got := tc.usage.CacheSavingsPercent(); got != tc.expectedPercent {
+				t.Errorf("Expected percent %v, got %v", tc.expectedPercent, got)
+			}
+		})
+	}
+}
+
+func TestFlattenContent(t *testing.T) {
+	blocks := []ContentBlock{
+		{Type: "thinking", Thinking: "hmm", Signature: "sig"},
+		{Type: "text", Text: "Here is the chart."},
+		{Type: "image", Source: &Image{Type: "base64", MediaType: MediaTypePNG, Data: "aGk="}},
+		{Type: "tool_use", ToolCall: &ToolCall{ID: "toolu_1", Name: "search", Input: json.RawMessage(`{"query":"weather"}`)}},
+		{Type: "tool_result", ToolOutput: &ToolOutput{ToolCallID: "toolu_1", Output: "sunny"}},
+		{Type: "web_search_tool_result", ToolUseID: "srvtoolu_1"},
+	}
+
+	expected := "[thinking]\n" +
+		"Here is the chart.\n" +
+		"[image]\n" +
+		`[tool_use: search({"query":"weather"})]` + "\n" +
+		"[tool_result toolu_1: sunny]\n" +
+		"[web_search_tool_result]"
+	if got := FlattenContent(blocks); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	if got := FlattenContent(nil); got != "" {
+		t.Errorf("Expected empty string for no blocks, got %q", got)
+	}
+}
@@ -0,0 +1,37 @@
+package anthropic
+
+// Pricing holds the price of a model in US dollars per million tokens.
+type Pricing struct {
+	InputPerMillion  float64
+	OutputPerMillion float64
+	// CacheWritePerMillion is the price of input tokens written to the prompt cache.
+	CacheWritePerMillion float64
+	// CacheReadPerMillion is the price of input tokens read from the prompt cache.
+	CacheReadPerMillion float64
+}
+
+// modelPricing lists the published prices of the known models. Cache writes cost
+// 1.25 times the input price and cache reads 0.1 times.
+var modelPricing = map[ModelID]Pricing{
+	ModelHaiku:  {InputPerMillion: 0.25, OutputPerMillion: 1.25, CacheWritePerMillion: 0.3, CacheReadPerMillion: 0.03},
+	ModelSonnet: {InputPerMillion: 3, OutputPerMillion: 15, CacheWritePerMillion: 3.75, CacheReadPerMillion: 0.3},
+	ModelOpus:   {InputPerMillion: 15, OutputPerMillion: 75, CacheWritePerMillion: 18.75, CacheReadPerMillion: 1.5},
+
+	ModelSonnet35: {InputPerMillion: 3, OutputPerMillion: 15, CacheWritePerMillion: 3.75, CacheReadPerMillion: 0.3},
+	ModelSonnet37: {InputPerMillion: 3, OutputPerMillion: 15, CacheWritePerMillion: 3.75, CacheReadPerMillion: 0.3},
+}
+
+// GetPricing returns the pricing of the given model.
+func GetPricing(model ModelID) (Pricing, bool) {
+	pricing, ok := modelPricing[model]
+	return pricing, ok
+}
+
+// Cost returns the cost in US dollars of the tokens in usage, including the input
+// tokens written to and read from the prompt cache.
+func (p Pricing) Cost(usage Usage) float64 {
+	return (float64(usage.InputTokens)*p.InputPerMillion +
+		float64(usage.OutputTokens)*p.OutputPerMillion +
+		float64(usage.CacheCreationInputTokens)*p.CacheWritePerMillion +
+		float64(usage.CacheReadInputTokens)*p.CacheReadPerMillion) / 1e6
+}
@@ -0,0 +1,92 @@
+package anthropic
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Rate limit response headers.
+const (
+	headerRequestsLimit     = "anthropic-ratelimit-requests-limit"
+	headerRequestsRemaining = "anthropic-ratelimit-requests-remaining"
+	headerRequestsReset     = "anthropic-ratelimit-requests-reset"
+	headerTokensLimit       = "anthropic-ratelimit-tokens-limit"
+	headerTokensRemaining   = "anthropic-ratelimit-tokens-remaining"
+	headerTokensReset       = "anthropic-ratelimit-tokens-reset"
+	headerRequestID         = "request-id"
+)
+
+// ResponseMeta holds metadata about the HTTP response a message was read from.
+type ResponseMeta struct {
+	// RequestID is the ID the API assigned to the request, useful when reporting issues.
+	RequestID string
+	// RateLimit is the rate limit state reported with the response.
+	RateLimit RateLimitInfo
+}
+
+// RateLimitInfo is the rate limit state reported by the anthropic-ratelimit-*
+// response headers. Fields whose header was absent or malformed are left zero.
+type RateLimitInfo struct {
+	RequestsLimit     int
+	RequestsRemaining int
+	RequestsReset     time.Time
+	TokensLimit       int
+	TokensRemaining   int
+	TokensReset       time.Time
+}
+
+// WaitDuration returns how long to wait before the next request fits in the rate
+// limit: zero while both the request and token budgets have capacity left, otherwise
+// the time until the latest reset among the exhausted budgets.
+func (r RateLimitInfo) WaitDuration() time.Duration {
+	return r.waitDurationAt(time.Now())
+}
+
+func (r RateLimitInfo) waitDurationAt(now time.Time) time.Duration {
+	var wait time.Duration
+	if r.RequestsLimit > 0 && r.RequestsRemaining <= 0 {
+		wait = max(wait, r.RequestsReset.Sub(now))
+	}
+	if r.TokensLimit > 0 && r.TokensRemaining <= 0 {
+		wait = max(wait, r.TokensReset.Sub(now))
+	}
+	return wait
+}
+
+// parseResponseMeta reads the response metadata from the response headers.
+func parseResponseMeta(header http.Header) *ResponseMeta {
+	return &ResponseMeta{
+		RequestID: header.Get(headerRequestID),
+		RateLimit: parseRateLimitInfo(header),
+	}
+}
+
+// parseRateLimitInfo reads the anthropic-ratelimit-* headers.
+func parseRateLimitInfo(header http.Header) RateLimitInfo {
+	return RateLimitInfo{
+		RequestsLimit:     headerInt(header, headerRequestsLimit),
+		RequestsRemaining: headerInt(header, headerRequestsRemaining),
+		RequestsReset:     headerTime(header, headerRequestsReset),
+		TokensLimit:       headerInt(header, headerTokensLimit),
+		TokensRemaining:   headerInt(header, headerTokensRemaining),
+		TokensReset:       headerTime(header, headerTokensReset),
+	}
+}
+
+func headerInt(header http.Header, key string) int {
+	value, err := strconv.Atoi(header.Get(key))
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// headerTime parses an RFC 3339 timestamp header.
+func headerTime(header http.Header, key string) time.Time {
+	value, err := time.Parse(time.RFC3339, header.Get(key))
+	if err != nil {
+		return time.Time{}
+	}
+	return value
+}
@@ -0,0 +1,116 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func setRateLimitHeaders(header http.Header, requestsRemaining, tokensRemaining string) {
+	header.Set(headerRequestID, "req_123")
+	header.Set(headerRequestsLimit, "50")
+	header.Set(headerRequestsRemaining, requestsRemaining)
+	header.Set(headerRequestsReset, "2025-01-01T00:00:30Z")
+	header.Set(headerTokensLimit, "40000")
+	header.Set(headerTokensRemaining, tokensRemaining)
+	header.Set(headerTokensReset, "2025-01-01T00:00:10Z")
+}
+
+func TestParseRateLimitInfo(t *testing.T) {
+	header := http.Header{}
+	setRateLimitHeaders(header, "49", "39000")
+	header.Set(headerTokensLimit, "not a number")
+
+	info := parseRateLimitInfo(header)
+	expected := RateLimitInfo{
+		RequestsLimit:     50,
+		RequestsRemaining: 49,
+		RequestsReset:     time.Date(2025, 1, 1, 0, 0, 30, 0, time.UTC),
+		TokensRemaining:   39000,
+		TokensReset:       time.Date(2025, 1, 1, 0, 0, 10, 0, time.UTC),
+	}
+	if info != expected {
+		t.Errorf("Expected %+v, got %+v", expected, info)
+	}
+}
+
+func TestRateLimitInfoWaitDuration(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	requestsReset := now.Add(30 * time.Second)
+	tokensReset := now.Add(10 * time.Second)
+
+	testCases := []struct {
+		name     string
+		info     RateLimitInfo
+		expected time.Duration
+	}{
+		{name: "No headers", info: RateLimitInfo{}, expected: 0},
+		{
+			name:     "Budget left",
+			info:     RateLimitInfo{RequestsLimit: 50, RequestsRemaining: 1, RequestsReset: requestsReset, TokensLimit: 100, TokensRemaining: 10, TokensReset: tokensReset},
+			expected: 0,
+		},
+		{
+			name:     "Tokens exhausted",
+			info:     RateLimitInfo{RequestsLimit: 50, RequestsRemaining: 1, RequestsReset: requestsReset, TokensLimit: 100, TokensRemaining: 0, TokensReset: tokensReset},
+			expected: 10 * time.Second,
+		},
+		{
+			name:     "Both exhausted",
+			info:     RateLimitInfo{RequestsLimit: 50, RequestsRemaining: 0, RequestsReset: requestsReset, TokensLimit: 100, TokensRemaining: 0, TokensReset: tokensReset},
+			expected: 30 * time.Second,
+		},
+		{
+			name:     "Reset in the past",
+			info:     RateLimitInfo{RequestsLimit: 50, RequestsRemaining: 0, RequestsReset: now.Add(-time.Second)},
+			expected: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.info.waitDurationAt(now); got != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestCreateResponseMeta(t *testing.T) {
+	limited := false
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		header := http.Header{}
+		if limited {
+			setRateLimitHeaders(header, "49", "0")
+			return testResponse{
+				status: http.StatusTooManyRequests,
+				header: header,
+				body:   `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`,
+			}
+		}
+		setRateLimitHeaders(header, "49", "39000")
+		return testResponse{header: header, value: Message{ID: "msg_123", Role: "assistant"}}
+	})
+	defer server.Close()
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	message, err := client.Messages().Create(context.Background(), &MessageParams{Model: string(ModelSonnet)})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if message.Meta == nil || message.Meta.RequestID != "req_123" || message.Meta.RateLimit.TokensRemaining != 39000 {
+		t.Errorf("Expected response meta from the headers, got %+v", message.Meta)
+	}
+
+	limited = true
+	_, err = client.Messages().Create(context.Background(), &MessageParams{Model: string(ModelSonnet)})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an *APIError, got %v", err)
+	}
+	if apiErr.RateLimit.TokensLimit != 40000 || apiErr.RateLimit.TokensRemaining != 0 {
+		t.Errorf("Expected the rate limit state on the error, got %+v", apiErr.RateLimit)
+	}
+}
@@ -0,0 +1,220 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	defaultRetryMaxDelay  = 8 * time.Second
+
+	// idempotencyKeyHeader carries a key that is identical across all attempts of a request.
+	idempotencyKeyHeader = "Idempotency-Key"
+)
+
+// ErrRequestBodyMutated is returned when a retry is refused because the request
+// body produced for the retry differs from the body sent on the first attempt.
+var ErrRequestBodyMutated = errors.New("request body was modified between attempts")
+
+// RequestEditor is a function that modifies an outgoing request before it is sent.
+// Editors run on every attempt, including retries.
+type RequestEditor func(ctx context.Context, req *http.Request) error
+
+// WithRequestEditor adds a function that is called on every outgoing request.
+func WithRequestEditor(editor RequestEditor) ClientOption {
+	return func(c *Client) error {
+		c.requestEditors = append(c.requestEditors, editor)
+		return nil
+	}
+}
+
+// WithMaxRetries sets how many times a non-streaming request is retried after a
+// retryable error (rate limiting, overload, or a transient server error).
+// Retries use exponential backoff with jitter. Streaming requests are not retried.
+//
+// Before each retry the request body is hashed and compared to the body sent on the
+// first attempt; if a RequestEditor changed it, the retry is refused with an error
+// wrapping ErrRequestBodyMutated so that a different request is never sent twice.
+func WithMaxRetries(maxRetries int) ClientOption {
+	return func(c *Client) error {
+		if maxRetries < 0 {
+			return fmt.Errorf("max retries must not be negative, got %d", maxRetries)
+		}
+		c.maxRetries = maxRetries
+		return nil
+	}
+}
+
+// WithIDGenerator sets the function used to generate idempotency keys, which are
+// sent with every attempt of a request when retries are enabled. It defaults to
+// random UUIDs from crypto/rand; tests can inject a deterministic generator.
+func WithIDGenerator(generate func() string) ClientOption {
+	return func(c *Client) error {
+		if generate == nil {
+			return fmt.Errorf("ID generator must not be nil")
+		}
+		c.idGenerator = generate
+		return nil
+	}
+}
+
+// WithJitterSource sets the source of randomness for retry backoff jitter, so tests
+// can assert deterministic delays.
+func WithJitterSource(src rand.Source) ClientOption {
+	return func(c *Client) error {
+		if src == nil {
+			return fmt.Errorf("jitter source must not be nil")
+		}
+		c.jitter = newLockedRand(src)
+		return nil
+	}
+}
+
+// IsRetryable reports whether the request that produced the error may succeed if retried.
+func (e *APIError) IsRetryable() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout, StatusOverloaded:
+		return true
+	}
+	return e.Type == ErrorTypeRateLimit || e.Type == ErrorTypeOverloaded || e.Type == ErrorTypeAPI
+}
+
+// sendWithRetry sends the messages request, retrying retryable failures, and returns
+// the successful response.
+func (s *Client) sendWithRetry(ctx context.Context, params *MessageParams, body []byte) (*http.Response, error) {
+	var idempotencyKey string
+	if s.maxRetries > 0 && !params.IsStreaming() {
+		idempotencyKey = s.idGenerator()
+	}
+
+	var firstHash [sha256.Size]byte
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		req, err := s.newMessagesRequest(ctx, params, body, idempotencyKey)
+		if err != nil {
+			return nil, err
+		}
+		sent, err := readRequestBody(req)
+		if err != nil {
+			return nil, err
+		}
+
+		hash := sha256.Sum256(sent)
+		if attempt == 0 {
+			firstHash = hash
+		} else if hash != firstHash {
+			return nil, fmt.Errorf("refusing to retry: %w: %w", ErrRequestBodyMutated, lastErr)
+		}
+
+		resp, err := s.doRequest(ctx, req, sent, params)
+		if err == nil {
+			return resp, nil
+		}
+		lastErr = err
+		if !s.shouldRetry(params, err, attempt) {
+			return nil, err
+		}
+
+		delay := s.retryDelay(attempt, err)
+		// Sleeping past the context's deadline would only end in a context error,
+		// hiding the actual failure, so give up right away instead.
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			s.log(ctx, LogLevelWarn, "not retrying request, context deadline is before the next attempt", "attempt", attempt+1, "delay", delay, "error", err)
+			return nil, err
+		}
+		s.log(ctx, LogLevelWarn, "retrying request", "attempt", attempt+1, "delay", delay, "error", err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// shouldRetry reports whether a failed attempt should be retried.
+func (s *Client) shouldRetry(params *MessageParams, err error, attempt int) bool {
+	if attempt >= s.maxRetries || params.IsStreaming() {
+		return false
+	}
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.IsRetryable()
+}
+
+// retryDelay returns the backoff before the given retry attempt: an exponentially
+// growing delay capped at the maximum, with up to 50% random jitter subtracted.
+// Overloaded errors start from a longer base delay.
+func (s *Client) retryDelay(attempt int, err error) time.Duration {
+	base := s.retryBaseDelay
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		base = apiErr.RecommendedBaseDelay(base)
+	}
+	delay := base << attempt
+	if delay <= 0 || delay > s.retryMaxDelay {
+		delay = s.retryMaxDelay
+	}
+	jitter := time.Duration(s.jitter.Int63n(int64(delay)/2 + 1))
+	return delay - jitter
+}
+
+// readRequestBody reads the body of req and replaces it so the request can still be sent.
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error reading request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	return body, nil
+}
+
+// lockedRand is a *rand.Rand that is safe for concurrent use.
+type lockedRand struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func newLockedRand(src rand.Source) *lockedRand {
+	return &lockedRand{r: rand.New(src)}
+}
+
+// Int63n returns a non-negative pseudo-random number in [0,n).
+func (l *lockedRand) Int63n(n int64) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Int63n(n)
+}
+
+// newUUID returns a random version 4 UUID generated from crypto/rand.
+func newUUID() string {
+	var b [16]byte
+	if _, err := cryptorand.Read(b[:]); err != nil {
+		// crypto/rand does not fail on supported platforms; fall back to math/rand.
+		for i := range b {
+			b[i] = byte(rand.Intn(256))
+		}
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
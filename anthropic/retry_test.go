@@ -0,0 +1,234 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func newRetryTestClient(t *testing.T, url string, opts ...ClientOption) *Client {
+	t.Helper()
+	opts = append([]ClientOption{WithAPIKey("test-key"), WithBaseURL(url)}, opts...)
+	client, err := NewClient(opts...)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.retryBaseDelay = time.Millisecond
+	client.retryMaxDelay = 5 * time.Millisecond
+	return client
+}
+
+func TestCreateRetriesRetryableErrors(t *testing.T) {
+	var bodies []string
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) < 3 {
+			return testResponse{status: http.StatusTooManyRequests, body: `{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`}
+		}
+		return testResponse{value: Message{ID: "msg_123", Role: "assistant"}}
+	})
+	defer server.Close()
+
+	client := newRetryTestClient(t, server.URL,
+		WithMaxRetries(3),
+		WithRequestEditor(func(ctx context.Context, req *http.Request) error {
+			req.Header.Set("X-Trace", "abc")
+			return nil
+		}),
+	)
+
+	message, err := client.Messages().Create(context.Background(), &MessageParams{Model: string(ModelSonnet)})
+	if err != nil {
+		t.Fatalf("Expected retries to succeed, got %v", err)
+	}
+	if message.ID != "msg_123" {
+		t.Errorf("Expected message ID 'msg_123', got '%s'", message.ID)
+	}
+	if len(bodies) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(bodies))
+	}
+	if bodies[0] != bodies[1] || bodies[1] != bodies[2] {
+		t.Errorf("Expected identical bodies on retries, got %v", bodies)
+	}
+}
+
+func TestCreateDoesNotRetryNonRetryableErrors(t *testing.T) {
+	attempts := 0
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		attempts++
+		return testResponse{status: http.StatusBadRequest, body: `{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`}
+	})
+	defer server.Close()
+
+	client := newRetryTestClient(t, server.URL, WithMaxRetries(3))
+
+	_, err := client.Messages().Create(context.Background(), &MessageParams{Model: string(ModelSonnet)})
+	if err == nil {
+		t.Fatal("Expected an error, got none")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestCreateDoesNotSleepPastContextDeadline(t *testing.T) {
+	attempts := 0
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		attempts++
+		return testResponse{status: http.StatusInternalServerError, body: `{"type":"error","error":{"type":"api_error","message":"internal"}}`}
+	})
+	defer server.Close()
+
+	client := newRetryTestClient(t, server.URL, WithMaxRetries(10), WithJitterSource(rand.NewSource(1)))
+	client.retryBaseDelay = 40 * time.Millisecond
+	client.retryMaxDelay = time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.Messages().Create(ctx, &MessageParams{Model: string(ModelSonnet)})
+	elapsed := time.Since(start)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the last API error rather than a context error, got %v", err)
+	}
+	if elapsed >= 300*time.Millisecond {
+		t.Errorf("Expected to give up before the deadline, took %v", elapsed)
+	}
+	if attempts < 2 || attempts > 10 {
+		t.Errorf("Expected several attempts before giving up, got %d", attempts)
+	}
+}
+
+func TestCreateRefusesRetryWhenEditorMutatesBody(t *testing.T) {
+	attempts := 0
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		attempts++
+		return testResponse{status: http.StatusServiceUnavailable, body: `{"type":"error","error":{"type":"api_error","message":"unavailable"}}`}
+	})
+	defer server.Close()
+
+	calls := 0
+	client := newRetryTestClient(t, server.URL,
+		WithMaxRetries(3),
+		WithRequestEditor(func(ctx context.Context, req *http.Request) error {
+			calls++
+			body, _ := io.ReadAll(req.Body)
+			body = append(bytes.TrimSuffix(body, []byte("}")), []byte(`,"nonce":`+string(rune('0'+calls))+`}`)...)
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			return nil
+		}),
+	)
+
+	_, err := client.Messages().Create(context.Background(), &MessageParams{Model: string(ModelSonnet)})
+	if !errors.Is(err, ErrRequestBodyMutated) {
+		t.Fatalf("Expected ErrRequestBodyMutated, got %v", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected the last API error to be wrapped, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected only the first attempt to be sent, got %d", attempts)
+	}
+}
+
+func TestAPIErrorIsRetryable(t *testing.T) {
+	testCases := []struct {
+		err      *APIError
+		expected bool
+	}{
+		{err: &APIError{StatusCode: http.StatusTooManyRequests}, expected: true},
+		{err: &APIError{StatusCode: http.StatusInternalServerError}, expected: true},
+		{err: &APIError{StatusCode: StatusOverloaded}, expected: true},
+		{err: &APIError{StatusCode: http.StatusBadRequest, Type: ErrorTypeInvalidRequest}, expected: false},
+		{err: &APIError{StatusCode: http.StatusUnauthorized, Type: ErrorTypeAuthentication}, expected: false},
+	}
+	for _, tc := range testCases {
+		if got := tc.err.IsRetryable(); got != tc.expected {
+			t.Errorf("Expected IsRetryable()=%v for status %d, got %v", tc.expected, tc.err.StatusCode, got)
+		}
+	}
+}
+
+func TestCreateSendsStableIdempotencyKey(t *testing.T) {
+	var keys []string
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		keys = append(keys, r.Header.Get(idempotencyKeyHeader))
+		if len(keys) == 1 {
+			return testResponse{status: http.StatusInternalServerError, body: `{"type":"error","error":{"type":"api_error","message":"oops"}}`}
+		}
+		return testResponse{value: Message{ID: "msg_123", Role: "assistant"}}
+	})
+	defer server.Close()
+
+	next := 0
+	client := newRetryTestClient(t, server.URL,
+		WithMaxRetries(2),
+		WithIDGenerator(func() string {
+			next++
+			return fmt.Sprintf("key-%d", next)
+		}),
+	)
+
+	params := &MessageParams{Model: string(ModelSonnet)}
+	if _, err := client.Messages().Create(context.Background(), params); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := client.Messages().Create(context.Background(), params); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"key-1", "key-1", "key-2"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected idempotency keys %v, got %v", expected, keys)
+	}
+}
+
+func TestRetryDelayDeterministicWithJitterSource(t *testing.T) {
+	delays := func() []time.Duration {
+		client := newRetryTestClient(t, "http://localhost", WithJitterSource(rand.NewSource(42)))
+		client.retryBaseDelay = 100 * time.Millisecond
+		client.retryMaxDelay = time.Second
+		var result []time.Duration
+		for attempt := 0; attempt < 5; attempt++ {
+			result = append(result, client.retryDelay(attempt, errors.New("transient")))
+		}
+		return result
+	}
+
+	first, second := delays(), delays()
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected identical delays for the same source, got %v and %v", first, second)
+	}
+	for attempt, delay := range first {
+		maxDelay := 100 * time.Millisecond << attempt
+		if maxDelay > time.Second {
+			maxDelay = time.Second
+		}
+		if delay > maxDelay || delay < maxDelay/2 {
+			t.Errorf("Attempt %d: expected delay in [%v, %v], got %v", attempt, maxDelay/2, maxDelay, delay)
+		}
+	}
+}
+
+func TestNewUUID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	first, second := newUUID(), newUUID()
+	if !pattern.MatchString(first) {
+		t.Errorf("Expected a version 4 UUID, got %s", first)
+	}
+	if first == second {
+		t.Errorf("Expected unique UUIDs, got %s twice", first)
+	}
+}
@@ -0,0 +1,68 @@
+package anthropic
+
+import "fmt"
+
+// Ptr returns a pointer to v, for setting optional fields such as
+// MessageParams.Temperature.
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// WithDefaultTemperature sets the temperature used by messages requests that leave
+// MessageParams.Temperature nil. A temperature set on the request always wins.
+func WithDefaultTemperature(temperature float64) ClientOption {
+	return func(c *Client) error {
+		if temperature < 0 || temperature > 1 {
+			return fmt.Errorf("temperature must be between 0 and 1, got %v", temperature)
+		}
+		c.defaultTemperature = &temperature
+		return nil
+	}
+}
+
+// WithDefaultTopP sets the top_p used by messages requests that leave
+// MessageParams.TopP nil. A top_p set on the request always wins.
+func WithDefaultTopP(topP float64) ClientOption {
+	return func(c *Client) error {
+		if topP < 0 || topP > 1 {
+			return fmt.Errorf("top_p must be between 0 and 1, got %v", topP)
+		}
+		c.defaultTopP = &topP
+		return nil
+	}
+}
+
+// WithDefaultTopK sets the top_k used by messages requests that leave
+// MessageParams.TopK nil. A top_k set on the request always wins.
+func WithDefaultTopK(topK int) ClientOption {
+	return func(c *Client) error {
+		if topK < 0 {
+			return fmt.Errorf("top_k must not be negative, got %d", topK)
+		}
+		c.defaultTopK = &topK
+		return nil
+	}
+}
+
+// withSamplingDefaults returns params with the client's sampling defaults filled in
+// for the fields the request leaves nil, or params unchanged if there is nothing to fill.
+func (c *Client) withSamplingDefaults(params *MessageParams) *MessageParams {
+	fillTemperature := params.Temperature == nil && c.defaultTemperature != nil
+	fillTopP := params.TopP == nil && c.defaultTopP != nil
+	fillTopK := params.TopK == nil && c.defaultTopK != nil
+	if !fillTemperature && !fillTopP && !fillTopK {
+		return params
+	}
+
+	withDefaults := *params
+	if fillTemperature {
+		withDefaults.Temperature = Ptr(*c.defaultTemperature)
+	}
+	if fillTopP {
+		withDefaults.TopP = Ptr(*c.defaultTopP)
+	}
+	if fillTopK {
+		withDefaults.TopK = Ptr(*c.defaultTopK)
+	}
+	return &withDefaults
+}
@@ -0,0 +1,87 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestWithSamplingDefaults(t *testing.T) {
+	var body map[string]interface{}
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		body = nil
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		return testResponse{value: Message{ID: "msg_123", Role: "assistant"}}
+	})
+	defer server.Close()
+
+	testCases := []struct {
+		name     string
+		opts     []ClientOption
+		params   *MessageParams
+		expected map[string]interface{}
+	}{
+		{
+			name:     "No defaults",
+			params:   &MessageParams{},
+			expected: map[string]interface{}{},
+		},
+		{
+			name:     "Defaults fill unset fields",
+			opts:     []ClientOption{WithDefaultTemperature(0.2), WithDefaultTopP(0.9), WithDefaultTopK(40)},
+			params:   &MessageParams{},
+			expected: map[string]interface{}{"temperature": 0.2, "top_p": 0.9, "top_k": float64(40)},
+		},
+		{
+			name:     "Per-call values win",
+			opts:     []ClientOption{WithDefaultTemperature(0.2), WithDefaultTopK(40)},
+			params:   &MessageParams{Temperature: Ptr(0.7), TopK: Ptr(5)},
+			expected: map[string]interface{}{"temperature": 0.7, "top_k": float64(5)},
+		},
+		{
+			name:     "Explicit zero wins",
+			opts:     []ClientOption{WithDefaultTemperature(0.2)},
+			params:   &MessageParams{Temperature: Ptr(0.0)},
+			expected: map[string]interface{}{"temperature": float64(0)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := NewClient(append([]ClientOption{WithAPIKey("test-key"), WithBaseURL(server.URL)}, tc.opts...)...)
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			tc.params.Model = string(ModelSonnet)
+			original := *tc.params
+			if _, err := client.Messages().Create(context.Background(), tc.params); err != nil {
+				t.Fatalf("Failed to create message: %v", err)
+			}
+
+			sampling := map[string]interface{}{}
+			for _, key := range []string{"temperature", "top_p", "top_k"} {
+				if value, ok := body[key]; ok {
+					sampling[key] = value
+				}
+			}
+			if !reflect.DeepEqual(sampling, tc.expected) {
+				t.Errorf("Expected sampling fields %v, got %v", tc.expected, sampling)
+			}
+			if !reflect.DeepEqual(*tc.params, original) {
+				t.Errorf("Expected the caller's params to be left unchanged")
+			}
+		})
+	}
+}
+
+func TestWithSamplingDefaultsInvalid(t *testing.T) {
+	for _, opt := range []ClientOption{WithDefaultTemperature(1.5), WithDefaultTopP(-0.1), WithDefaultTopK(-1)} {
+		if _, err := NewClient(WithAPIKey("test-key"), opt); err == nil {
+			t.Error("Expected an error for an out of range default")
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// testResponse is a response served by newTestServer. A zero status means 200 OK.
+// The response is an event stream if events is set, and JSON otherwise: value
+// encoded as JSON if it is non-nil, or else body verbatim. Entries in header are set
+// after the Content-Type, so they can override it. If stall is set, the response is
+// held open once written, until stall is closed or the request is canceled.
+type testResponse struct {
+	status int
+	header http.Header
+	body   string
+	value  interface{}
+	events []string
+	stall  <-chan struct{}
+}
+
+// newTestServer starts a server that answers each request with the response built
+// by respond, which may also record or check the request.
+func newTestServer(t *testing.T, respond func(r *http.Request) testResponse) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := respond(r)
+		if resp.stall != nil {
+			defer func() {
+				select {
+				case <-resp.stall:
+				case <-r.Context().Done():
+				}
+			}()
+		}
+		status := resp.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		if resp.events != nil {
+			w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		for key, values := range resp.header {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(status)
+
+		if resp.events != nil {
+			flusher, ok := w.(http.Flusher)
+			if !ok {
+				t.Error("Expected http.ResponseWriter to be an http.Flusher")
+				return
+			}
+			for _, event := range resp.events {
+				if _, err := w.Write([]byte("data: " + event + "\n\n")); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+			return
+		}
+		if resp.value != nil {
+			if err := json.NewEncoder(w).Encode(resp.value); err != nil {
+				t.Errorf("Failed to encode response: %v", err)
+			}
+			return
+		}
+		_, _ = w.Write([]byte(resp.body))
+	}))
+}
+
+// respondWith returns a respond function for newTestServer that answers every request
+// with resp.
+func respondWith(resp testResponse) func(r *http.Request) testResponse {
+	return func(r *http.Request) testResponse { return resp }
+}
+
+// concurrencyTracker records the highest number of requests a test server handles at
+// the same time.
+type concurrencyTracker struct {
+	inFlight, maxInFlight atomic.Int32
+}
+
+// enter marks the start of a request and returns a function marking its end.
+func (c *concurrencyTracker) enter() func() {
+	current := c.inFlight.Add(1)
+	for {
+		seen := c.maxInFlight.Load()
+		if current <= seen || c.maxInFlight.CompareAndSwap(seen, current) {
+			break
+		}
+	}
+	return func() { c.inFlight.Add(-1) }
+}
+
+// max returns the highest number of concurrent requests seen so far.
+func (c *concurrencyTracker) max() int32 {
+	return c.maxInFlight.Load()
+}
@@ -0,0 +1,92 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// sseTextDelta is the payload written for each text delta by StreamToSSE.
+type sseTextDelta struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// sseMessageStop is the payload written once the stream completes.
+type sseMessageStop struct {
+	Type       string `json:"type"`
+	StopReason string `json:"stop_reason,omitempty"`
+}
+
+// sseError is the payload written when the upstream request fails.
+type sseError struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// StreamToSSE streams a message to w as server-sent events, e.g. to proxy a response
+// to a browser. Each text delta is written as a "content_block_delta" event and
+// flushed immediately; a final "message_stop" event (or "error" event on failure)
+// ends the stream. The assembled Message is returned as with Create.
+//
+// If writing to w fails, typically because the client disconnected, the upstream
+// request is cancelled. Any StreamFunc set on params is still invoked.
+func (s *MessagesService) StreamToSSE(ctx context.Context, params *MessageParams, w http.ResponseWriter) (*Message, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	writeEvent := func(name string, payload interface{}) error {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("error marshaling SSE event: %w", err)
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+			return fmt.Errorf("error writing SSE event: %w", err)
+		}
+		if err := rc.Flush(); err != nil {
+			return fmt.Errorf("error flushing SSE event: %w", err)
+		}
+		return nil
+	}
+
+	streamParams := *params
+	streamFunc := params.StreamFunc
+	streamParams.StreamFunc = func(ctx context.Context, chunk []byte) error {
+		if streamFunc != nil {
+			if err := streamFunc(ctx, chunk); err != nil {
+				return err
+			}
+		}
+		if len(chunk) == 0 {
+			return nil
+		}
+		if err := writeEvent("content_block_delta", sseTextDelta{Type: "text_delta", Text: string(chunk)}); err != nil {
+			cancel()
+			return err
+		}
+		return nil
+	}
+
+	message, err := s.client.Create(ctx, &streamParams)
+	if err != nil {
+		if ctx.Err() == nil {
+			_ = writeEvent("error", sseError{Type: "error", Error: err.Error()})
+		}
+		return message, err
+	}
+
+	stop := sseMessageStop{Type: "message_stop"}
+	if message != nil {
+		stop.StopReason = message.StopReason
+	}
+	if err := writeEvent("message_stop", stop); err != nil {
+		return message, err
+	}
+	return message, nil
+}
@@ -0,0 +1,127 @@
+package anthropic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var helloStreamEvents = []string{
+	`{"type":"message_start","message":{"id":"msg_123","role":"assistant","model":"claude-3-sonnet-20240229","usage":{"input_tokens":10}}}`,
+	`{"type":"content_block_start","index":0,"content_block":{"type":"text"}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":", world!"}}`,
+	`{"type":"content_block_stop","index":0}`,
+	`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":20}}`,
+	`{"type":"message_stop"}`,
+}
+
+func TestMessagesService_StreamToSSE(t *testing.T) {
+	server := newTestServer(t, respondWith(testResponse{events: helloStreamEvents}))
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	recorder := httptest.NewRecorder()
+
+	message, err := client.Messages().StreamToSSE(context.Background(), &MessageParams{Model: string(ModelSonnet)}, recorder)
+	if err != nil {
+		t.Fatalf("Failed to stream to SSE: %v", err)
+	}
+	if message.Content[0].Text != "Hello, world!" {
+		t.Errorf("Expected assembled text 'Hello, world!', got %+v", message.Content)
+	}
+	if recorder.Header().Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected Content-Type text/event-stream, got %s", recorder.Header().Get("Content-Type"))
+	}
+	if !recorder.Flushed {
+		t.Errorf("Expected the response writer to be flushed")
+	}
+
+	expected := "event: content_block_delta\ndata: {\"type\":\"text_delta\",\"text\":\"Hello\"}\n\n" +
+		"event: content_block_delta\ndata: {\"type\":\"text_delta\",\"text\":\", world!\"}\n\n" +
+		"event: message_stop\ndata: {\"type\":\"message_stop\",\"stop_reason\":\"end_turn\"}\n\n"
+	if recorder.Body.String() != expected {
+		t.Errorf("Expected SSE body %q, got %q", expected, recorder.Body.String())
+	}
+}
+
+// disconnectedWriter simulates a client that went away after the first write.
+type disconnectedWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (w *disconnectedWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes > 1 {
+		return 0, errors.New("client disconnected")
+	}
+	return w.ResponseRecorder.Write(p)
+}
+
+func TestMessagesService_StreamToSSEClientDisconnect(t *testing.T) {
+	server := newTestServer(t, respondWith(testResponse{events: helloStreamEvents}))
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	writer := &disconnectedWriter{ResponseRecorder: httptest.NewRecorder()}
+
+	message, err := client.Messages().StreamToSSE(context.Background(), &MessageParams{Model: string(ModelSonnet)}, writer)
+	if err == nil || !strings.Contains(err.Error(), "client disconnected") {
+		t.Fatalf("Expected client disconnect error, got %v", err)
+	}
+	if message == nil || message.ID != "msg_123" {
+		t.Errorf("Expected the partial message msg_123, got %+v", message)
+	}
+	if writer.writes != 2 {
+		t.Errorf("Expected streaming to stop after the failed write, got %d writes", writer.writes)
+	}
+}
+
+func TestCreateStreamingGzipEncoded(t *testing.T) {
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	for _, event := range helloStreamEvents {
+		_, _ = gzipWriter.Write([]byte("data: " + event + "\n\n"))
+	}
+	_ = gzipWriter.Close()
+	server := newTestServer(t, respondWith(testResponse{
+		header: http.Header{"Content-Type": {"text/event-stream"}, "Content-Encoding": {"gzip"}},
+		body:   compressed.String(),
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name       string
+		httpClient *http.Client
+	}{
+		{name: "Compression disabled on transport", httpClient: &http.Client{Transport: &http.Transport{DisableCompression: true}}},
+		{name: "Default transport", httpClient: &http.Client{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL), WithHTTPClient(tc.httpClient))
+			var streamed strings.Builder
+			params := &MessageParams{
+				Model: string(ModelSonnet),
+				StreamFunc: func(ctx context.Context, chunk []byte) error {
+					streamed.Write(chunk)
+					return nil
+				},
+			}
+			message, err := client.Messages().Create(context.Background(), params)
+			if err != nil {
+				t.Fatalf("Failed to create message: %v", err)
+			}
+			if message.Text() != "Hello, world!" || streamed.String() != "Hello, world!" {
+				t.Errorf("Expected %q, got message %q and streamed %q", "Hello, world!", message.Text(), streamed.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package anthropic
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// TaggedStreamEvent is an event of one of the requests started by StreamMany.
+// Index identifies the request by its position in the slice passed to StreamMany.
+// Events carrying a Chunk are streamed text, never empty; the last event of each
+// request has Done set, along with the assembled Message or the request's Err.
+type TaggedStreamEvent struct {
+	Index   int
+	Chunk   []byte
+	Done    bool
+	Message *Message
+	Err     error
+}
+
+// StreamMany sends the given requests concurrently as streaming requests and
+// multiplexes their events onto a single channel, which is closed once every request
+// has completed. Events of one request arrive in order, but events of different
+// requests are interleaved.
+//
+// The caller must drain the channel or cancel ctx; a cancelled context aborts the
+// remaining streams and stops sending events. A StreamFunc set on params is still
+// called for each chunk. The params are not modified; a nil element completes with
+// an error instead of sending a request.
+func (s *MessagesService) StreamMany(ctx context.Context, params []*MessageParams) <-chan TaggedStreamEvent {
+	events := make(chan TaggedStreamEvent)
+
+	send := func(event TaggedStreamEvent) error {
+		select {
+		case events <- event:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, p := range params {
+		wg.Add(1)
+		go func(index int, p *MessageParams) {
+			defer wg.Done()
+			if p == nil {
+				_ = send(TaggedStreamEvent{Index: index, Done: true, Err: fmt.Errorf("params %d is nil", index)})
+				return
+			}
+
+			tagged := *p
+			streamFunc := p.StreamFunc
+			tagged.StreamFunc = func(ctx context.Context, chunk []byte) error {
+				if streamFunc != nil {
+					if err := streamFunc(ctx, chunk); err != nil {
+						return err
+					}
+				}
+				// Deltas without text, such as thinking or tool input, produce empty chunks.
+				if len(chunk) == 0 {
+					return nil
+				}
+				return send(TaggedStreamEvent{Index: index, Chunk: append([]byte(nil), chunk...)})
+			}
+
+			message, err := s.Create(ctx, &tagged)
+			_ = send(TaggedStreamEvent{Index: index, Done: true, Message: message, Err: err})
+		}(i, p)
+	}
+
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+	return events
+}
@@ -0,0 +1,165 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoStreamResponse streams the text of each request's first message back one
+// character per delta.
+func echoStreamResponse(t *testing.T) func(r *http.Request) testResponse {
+	return func(r *http.Request) testResponse {
+		var params MessageParams
+		if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+			return testResponse{status: http.StatusBadRequest}
+		}
+		text := params.Messages[0].Content[0].Text
+
+		events := []string{
+			`{"type":"message_start","message":{"id":"msg_123","role":"assistant","usage":{"input_tokens":10}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text"}}`,
+		}
+		for _, r := range text {
+			events = append(events, fmt.Sprintf(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":%q}}`, string(r)))
+		}
+		events = append(events, `{"type":"content_block_stop","index":0}`, `{"type":"message_stop"}`)
+		return testResponse{events: events}
+	}
+}
+
+func TestMessagesService_StreamMany(t *testing.T) {
+	server := newTestServer(t, echoStreamResponse(t))
+	defer server.Close()
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	prompts := []string{"alpha", "beta", "gamma"}
+	params := make([]*MessageParams, len(prompts))
+	for i, prompt := range prompts {
+		params[i] = &MessageParams{
+			Model:    string(ModelSonnet),
+			Messages: []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: prompt}}}},
+		}
+	}
+
+	chunks := make([]string, len(prompts))
+	done := make([]int, len(prompts))
+	for event := range client.Messages().StreamMany(context.Background(), params) {
+		if event.Done {
+			done[event.Index]++
+			if event.Err != nil {
+				t.Fatalf("Request %d failed: %v", event.Index, event.Err)
+			}
+			if event.Message.Text() != prompts[event.Index] {
+				t.Errorf("Expected message %d to be %q, got %q", event.Index, prompts[event.Index], event.Message.Text())
+			}
+			continue
+		}
+		if done[event.Index] > 0 {
+			t.Errorf("Received a chunk for request %d after it completed", event.Index)
+		}
+		chunks[event.Index] += string(event.Chunk)
+	}
+
+	for i, prompt := range prompts {
+		if chunks[i] != prompt {
+			t.Errorf("Expected chunks of request %d to be %q, got %q", i, prompt, chunks[i])
+		}
+		if done[i] != 1 {
+			t.Errorf("Expected exactly one done event for request %d, got %d", i, done[i])
+		}
+		if params[i].StreamFunc != nil {
+			t.Errorf("Expected params %d to be left unchanged", i)
+		}
+	}
+}
+
+func TestMessagesService_StreamManyCancel(t *testing.T) {
+	server := newTestServer(t, echoStreamResponse(t))
+	defer server.Close()
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	params := []*MessageParams{
+		{Model: string(ModelSonnet), Messages: []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "alpha"}}}}},
+		{Model: string(ModelSonnet), Messages: []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "beta"}}}}},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := client.Messages().StreamMany(ctx, params)
+	<-events
+	cancel()
+
+	closed := make(chan struct{})
+	go func() {
+		for range events {
+		}
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the event channel to close after cancellation")
+	}
+}
+
+func TestMessagesService_StreamManyNilParams(t *testing.T) {
+	server := newTestServer(t, echoStreamResponse(t))
+	defer server.Close()
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	params := []*MessageParams{
+		{Model: string(ModelSonnet), Messages: []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "alpha"}}}}},
+		nil,
+	}
+
+	done := make([]*TaggedStreamEvent, len(params))
+	for event := range client.Messages().StreamMany(context.Background(), params) {
+		if event.Done {
+			done[event.Index] = &event
+		}
+	}
+
+	if done[0] == nil || done[0].Err != nil || done[0].Message.Text() != "alpha" {
+		t.Errorf("Expected request 0 to complete with %q, got %+v", "alpha", done[0])
+	}
+	if done[1] == nil || done[1].Err == nil || done[1].Message != nil {
+		t.Fatalf("Expected an error event for the nil params, got %+v", done[1])
+	}
+	if !strings.Contains(done[1].Err.Error(), "params 1") {
+		t.Errorf("Expected the error to identify params 1, got %v", done[1].Err)
+	}
+}
+
+func TestMessagesService_StreamManySkipsEmptyChunks(t *testing.T) {
+	server := newTestServer(t, respondWith(testResponse{events: []string{
+		`{"type":"message_start","message":{"id":"msg_123","role":"assistant","usage":{"input_tokens":10}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Let me think."}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Hi"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_stop"}`,
+	}}))
+	defer server.Close()
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	var chunks []string
+	for event := range client.Messages().StreamMany(context.Background(), []*MessageParams{{Model: string(ModelSonnet37)}}) {
+		if event.Done {
+			if event.Err != nil {
+				t.Fatalf("Request failed: %v", event.Err)
+			}
+			continue
+		}
+		chunks = append(chunks, string(event.Chunk))
+	}
+	if len(chunks) != 1 || chunks[0] != "Hi" {
+		t.Errorf("Expected only the text chunk, got %q", chunks)
+	}
+}
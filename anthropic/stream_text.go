@@ -0,0 +1,51 @@
+package anthropic
+
+import "context"
+
+// StreamText sends params as a streaming request and returns a channel of the text
+// fragments as they arrive, for consumers that only want the generated text:
+//
+//	text, errc := client.Messages().StreamText(ctx, params)
+//	for fragment := range text {
+//		fmt.Print(fragment)
+//	}
+//	if err := <-errc; err != nil {
+//		log.Fatal(err)
+//	}
+//
+// The text channel is closed when the request completes; the error channel then
+// yields the request's error, nil on success, and is closed. The caller must drain
+// the text channel or cancel ctx. A StreamFunc set on params is still called for each
+// chunk. The params are not modified.
+func (s *MessagesService) StreamText(ctx context.Context, params *MessageParams) (<-chan string, <-chan error) {
+	text := make(chan string)
+	errc := make(chan error, 1)
+
+	streaming := *params
+	streamFunc := params.StreamFunc
+	streaming.StreamFunc = func(ctx context.Context, chunk []byte) error {
+		if streamFunc != nil {
+			if err := streamFunc(ctx, chunk); err != nil {
+				return err
+			}
+		}
+		// Deltas without text, such as thinking or tool input, produce empty chunks.
+		if len(chunk) == 0 {
+			return nil
+		}
+		select {
+		case text <- string(chunk):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	go func() {
+		defer close(errc)
+		_, err := s.Create(ctx, &streaming)
+		close(text)
+		errc <- err
+	}()
+	return text, errc
+}
@@ -0,0 +1,72 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMessagesService_StreamText(t *testing.T) {
+	server := newTestServer(t, respondWith(testResponse{events: helloStreamEvents}))
+	defer server.Close()
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	var streamed []string
+	params := &MessageParams{
+		Model: string(ModelSonnet),
+		StreamFunc: func(ctx context.Context, chunk []byte) error {
+			streamed = append(streamed, string(chunk))
+			return nil
+		},
+	}
+	text, errc := client.Messages().StreamText(context.Background(), params)
+
+	var fragments []string
+	for fragment := range text {
+		fragments = append(fragments, fragment)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(fragments, "") != "Hello, world!" || len(fragments) < 2 {
+		t.Errorf("Expected the text in several fragments, got %q", fragments)
+	}
+	if strings.Join(streamed, "") != "Hello, world!" {
+		t.Errorf("Expected the caller's StreamFunc to still be called, got %q", streamed)
+	}
+	if _, ok := <-errc; ok {
+		t.Error("Expected the error channel to be closed")
+	}
+}
+
+func TestMessagesService_StreamTextError(t *testing.T) {
+	server := newTestServer(t, respondWith(testResponse{status: http.StatusBadRequest, body: `{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`}))
+	defer server.Close()
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	text, errc := client.Messages().StreamText(context.Background(), &MessageParams{Model: string(ModelSonnet)})
+	for fragment := range text {
+		t.Errorf("Unexpected fragment %q", fragment)
+	}
+	var apiErr *APIError
+	if err := <-errc; !errors.As(err, &apiErr) {
+		t.Errorf("Expected an *APIError, got %v", err)
+	}
+}
+
+func TestMessagesService_StreamTextCancel(t *testing.T) {
+	server := newTestServer(t, respondWith(testResponse{events: helloStreamEvents}))
+	defer server.Close()
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	text, errc := client.Messages().StreamText(ctx, &MessageParams{Model: string(ModelSonnet)})
+	<-text
+	// Stop reading; cancelling must still let the request finish.
+	cancel()
+	if err := <-errc; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected an error wrapping context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// structuredOutputToolName is the name of the tool CreateStructured forces the model
+// to call so that its input carries the structured output.
+const structuredOutputToolName = "structured_output"
+
+// StructuredOption configures a CreateStructured call.
+type StructuredOption func(*structuredConfig)
+
+type structuredConfig struct {
+	retries int
+}
+
+// WithStructuredRetries makes CreateStructured retry up to n times when the model's
+// output does not match the schema, telling the model what was wrong each time.
+func WithStructuredRetries(n int) StructuredOption {
+	return func(c *structuredConfig) {
+		c.retries = n
+	}
+}
+
+// CreateStructured sends params and decodes the model's structured output, which must
+// match params.OutputSchema, into a new T. The output is obtained by forcing the model
+// to call a tool whose input schema is the output schema. Output with missing required
+// properties or with fields that do not exist in T is rejected. The params are not modified.
+//
+// With WithStructuredRetries, invalid output is sent back to the model along with the
+// reason it was rejected, and the model is asked again. If no attempt succeeds, the
+// error wraps the last decoding error.
+func CreateStructured[T any](ctx context.Context, s *MessagesService, params *MessageParams, opts ...StructuredOption) (*T, error) {
+	if params.OutputSchema == nil {
+		return nil, fmt.Errorf("OutputSchema is required for structured output")
+	}
+	var config structuredConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	tool := Tool{
+		Name:        structuredOutputToolName,
+		Description: "Respond with output matching this schema.",
+		InputSchema: *params.OutputSchema,
+	}
+	request := *params
+	request.OutputSchema = nil
+	request.Tools = append(append([]Tool(nil), params.Tools...), tool)
+	request.ToolChoice = &ToolChoice{Type: ToolChoiceTypeTool, Tool: &tool}
+	request.Messages = append([]MessageParam(nil), params.Messages...)
+
+	var lastErr error
+	for attempt := 0; attempt <= config.retries; attempt++ {
+		message, err := s.Create(ctx, &request)
+		if err != nil {
+			return nil, err
+		}
+
+		call := findToolCall(message, structuredOutputToolName)
+		if call == nil {
+			lastErr = fmt.Errorf("model did not call the %s tool", structuredOutputToolName)
+			request.Messages = append(request.Messages,
+				message.ToParam(),
+				MessageParam{Role: "user", Content: []ContentBlock{TextContent(
+					fmt.Sprintf("Your previous output was invalid because %v. Respond by calling the %s tool.", lastErr, structuredOutputToolName),
+				)}},
+			)
+			continue
+		}
+
+		output, err := decodeStructuredOutput[T](call.Input, params.OutputSchema)
+		if err == nil {
+			return output, nil
+		}
+		lastErr = err
+		request.Messages = append(request.Messages,
+			message.ToParam(),
+			MessageParam{Role: "user", Content: []ContentBlock{ToolResultContent(
+				call.ID,
+				fmt.Sprintf("Your previous output was invalid JSON because %v. Call the tool again with corrected output.", err),
+				true,
+			)}},
+		)
+	}
+	return nil, fmt.Errorf("invalid structured output after %d attempts: %w", config.retries+1, lastErr)
+}
+
+// findToolCall returns the first call to the named tool in message, or nil.
+func findToolCall(message *Message, name string) *ToolCall {
+	for _, block := range message.Content {
+		if block.Type == "tool_use" && block.ToolCall != nil && block.ToolCall.Name == name {
+			return block.ToolCall
+		}
+	}
+	return nil
+}
+
+// decodeStructuredOutput checks that input has the schema's required properties and
+// decodes it into a new T, rejecting fields that do not exist in T.
+func decodeStructuredOutput[T any](input json.RawMessage, schema *InputSchema) (*T, error) {
+	if len(schema.Required) > 0 {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(input, &fields); err != nil {
+			return nil, err
+		}
+		for _, name := range schema.Required {
+			if _, ok := fields[name]; !ok {
+				return nil, fmt.Errorf("required property %q is missing", name)
+			}
+		}
+	}
+
+	output := new(T)
+	decoder := json.NewDecoder(bytes.NewReader(input))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
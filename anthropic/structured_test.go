@@ -0,0 +1,147 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type contactInfo struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+var contactSchema = &InputSchema{
+	Type: "object",
+	Properties: map[string]interface{}{
+		"name":  map[string]interface{}{"type": "string"},
+		"email": map[string]interface{}{"type": "string"},
+	},
+	Required: []string{"name", "email"},
+}
+
+// structuredResponses answers each request with the next of the given tool inputs
+// as a structured_output tool call, recording the requests.
+func structuredResponses(t *testing.T, inputs []string, requests *[]map[string]interface{}) func(r *http.Request) testResponse {
+	return func(r *http.Request) testResponse {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		*requests = append(*requests, body)
+
+		input := inputs[len(*requests)-1]
+		return testResponse{body: fmt.Sprintf(`{"id":"msg_%d","role":"assistant","content":[{"type":"tool_use","id":"toolu_%d","name":"structured_output","input":%s}],"stop_reason":"tool_use"}`,
+			len(*requests), len(*requests), input)}
+	}
+}
+
+func TestCreateStructured(t *testing.T) {
+	testCases := []struct {
+		name             string
+		inputs           []string
+		retries          int
+		expectedErr      string
+		expectedRequests int
+	}{
+		{name: "Valid output", inputs: []string{`{"name":"Ada","email":"ada@example.com"}`}, expectedRequests: 1},
+		{
+			name:             "Missing property without retries",
+			inputs:           []string{`{"name":"Ada"}`},
+			expectedErr:      `required property "email" is missing`,
+			expectedRequests: 1,
+		},
+		{
+			name:             "Recovers after a retry",
+			inputs:           []string{`{"name":"Ada","mail":"ada@example.com"}`, `{"name":"Ada","email":"ada@example.com"}`},
+			retries:          2,
+			expectedRequests: 2,
+		},
+		{
+			name:             "Retries exhausted",
+			inputs:           []string{`{"name":1}`, `{"name":2}`},
+			retries:          1,
+			expectedErr:      "invalid structured output after 2 attempts",
+			expectedRequests: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests []map[string]interface{}
+			server := newTestServer(t, structuredResponses(t, tc.inputs, &requests))
+			defer server.Close()
+			client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+			params := &MessageParams{
+				Model:        string(ModelSonnet),
+				Messages:     []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Ada, ada@example.com"}}}},
+				OutputSchema: contactSchema,
+			}
+			contact, err := CreateStructured[contactInfo](context.Background(), client.Messages(), params, WithStructuredRetries(tc.retries))
+
+			if len(requests) != tc.expectedRequests {
+				t.Errorf("Expected %d requests, got %d", tc.expectedRequests, len(requests))
+			}
+			if tc.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+					t.Fatalf("Expected error containing %q, got %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if contact.Name != "Ada" || contact.Email != "ada@example.com" {
+				t.Errorf("Unexpected output: %+v", contact)
+			}
+			if len(params.Messages) != 1 || params.OutputSchema != contactSchema {
+				t.Errorf("Expected the caller's params to be left unchanged")
+			}
+		})
+	}
+}
+
+func TestCreateStructuredCorrectionMessage(t *testing.T) {
+	var requests []map[string]interface{}
+	server := newTestServer(t, structuredResponses(t, []string{`{"name":"Ada"}`, `{"name":"Ada","email":"ada@example.com"}`}, &requests))
+	defer server.Close()
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	params := &MessageParams{
+		Model:        string(ModelSonnet),
+		Messages:     []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Ada, ada@example.com"}}}},
+		OutputSchema: contactSchema,
+	}
+	if _, err := CreateStructured[contactInfo](context.Background(), client.Messages(), params, WithStructuredRetries(1)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, ok := requests[0]["output_schema"]; ok {
+		t.Errorf("Expected the schema to be sent as a forced tool, not output_schema")
+	}
+	if choice := requests[0]["tool_choice"].(map[string]interface{}); choice["type"] != ToolChoiceTypeTool {
+		t.Errorf("Expected the structured output tool to be forced, got %v", choice)
+	}
+
+	messages := requests[1]["messages"].([]interface{})
+	if len(messages) != 3 {
+		t.Fatalf("Expected the retry to include the invalid output and a correction, got %d messages", len(messages))
+	}
+	correction, _ := json.Marshal(messages[2])
+	for _, want := range []string{`"is_error":true`, `"tool_use_id":"toolu_1"`, `invalid JSON because required property \"email\" is missing`} {
+		if !strings.Contains(string(correction), want) {
+			t.Errorf("Expected the correction to contain %s, got %s", want, correction)
+		}
+	}
+}
+
+func TestCreateStructuredRequiresSchema(t *testing.T) {
+	client, _ := NewClient(WithAPIKey("test-key"))
+	if _, err := CreateStructured[contactInfo](context.Background(), client.Messages(), &MessageParams{Model: string(ModelSonnet)}); err == nil {
+		t.Error("Expected an error without OutputSchema")
+	}
+}
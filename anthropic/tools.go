@@ -0,0 +1,215 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// ToolHandler executes a tool call and returns its output.
+// The input is the raw JSON object the model provided for the call.
+type ToolHandler func(ctx context.Context, input json.RawMessage) (string, error)
+
+// ToolErrorPolicy determines how RunTools reacts to a tool handler error.
+type ToolErrorPolicy int
+
+const (
+	// ToolErrorAbort stops the tool loop and returns the handler error.
+	ToolErrorAbort ToolErrorPolicy = iota
+	// ToolErrorFeedback sends the error back to the model as a tool_result with
+	// is_error set, letting the model recover, and continues the loop.
+	ToolErrorFeedback
+)
+
+// defaultMaxToolIterations bounds the number of model calls made by RunTools.
+const defaultMaxToolIterations = 10
+
+// ToolRegistry maps tool definitions to the handlers that execute them.
+// It is safe for concurrent use.
+type ToolRegistry struct {
+	// OnToolError is the registry's default policy for handler errors in RunTools.
+	// It can be overridden per call with WithOnToolError.
+	OnToolError ToolErrorPolicy
+
+	mu       sync.RWMutex
+	order    []string
+	tools    map[string]Tool
+	handlers map[string]ToolHandler
+}
+
+// NewToolRegistry creates an empty ToolRegistry.
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools:    make(map[string]Tool),
+		handlers: make(map[string]ToolHandler),
+	}
+}
+
+// Register adds a tool and the handler that executes it.
+// It returns an error if the tool has no name or a tool with the same name is already registered.
+func (r *ToolRegistry) Register(tool Tool, handler ToolHandler) error {
+	if tool.Name == "" {
+		return fmt.Errorf("tool name is required")
+	}
+	if handler == nil {
+		return fmt.Errorf("handler for tool %q is nil", tool.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tools[tool.Name]; ok {
+		return fmt.Errorf("tool %q is already registered", tool.Name)
+	}
+	r.order = append(r.order, tool.Name)
+	r.tools[tool.Name] = tool
+	r.handlers[tool.Name] = handler
+	return nil
+}
+
+// RegisterTyped adds a tool whose handler receives its input decoded into a fresh *T.
+// Input fields that do not exist in T are rejected, so handlers only ever see input
+// that matches the struct.
+func RegisterTyped[T any](r *ToolRegistry, tool Tool, handler func(ctx context.Context, input *T) (string, error)) error {
+	if handler == nil {
+		return fmt.Errorf("handler for tool %q is nil", tool.Name)
+	}
+	return r.Register(tool, func(ctx context.Context, raw json.RawMessage) (string, error) {
+		input := new(T)
+		if len(raw) > 0 {
+			decoder := json.NewDecoder(bytes.NewReader(raw))
+			decoder.DisallowUnknownFields()
+			if err := decoder.Decode(input); err != nil {
+				return "", fmt.Errorf("invalid input for tool %q: %w", tool.Name, err)
+			}
+		}
+		return handler(ctx, input)
+	})
+}
+
+// Tools returns the registered tool definitions in registration order,
+// ready to be set as MessageParams.Tools.
+func (r *ToolRegistry) Tools() []Tool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tools := make([]Tool, 0, len(r.order))
+	for _, name := range r.order {
+		tools = append(tools, r.tools[name])
+	}
+	return tools
+}
+
+// Call executes the handler registered for the tool call's name.
+func (r *ToolRegistry) Call(ctx context.Context, call *ToolCall) (*ToolOutput, error) {
+	r.mu.RLock()
+	handler, ok := r.handlers[call.Name]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no handler registered for tool %q", call.Name)
+	}
+
+	output, err := handler(ctx, call.Input)
+	if err != nil {
+		return nil, err
+	}
+	return &ToolOutput{ToolCallID: call.ID, Output: output}, nil
+}
+
+// RunToolsOption configures a single RunTools call.
+type RunToolsOption func(*runToolsConfig)
+
+type runToolsConfig struct {
+	onToolError   ToolErrorPolicy
+	maxIterations int
+}
+
+// WithOnToolError overrides the registry's OnToolError policy for a RunTools call.
+func WithOnToolError(policy ToolErrorPolicy) RunToolsOption {
+	return func(c *runToolsConfig) {
+		c.onToolError = policy
+	}
+}
+
+// WithMaxToolIterations limits the number of model calls a RunTools call may make.
+func WithMaxToolIterations(n int) RunToolsOption {
+	return func(c *runToolsConfig) {
+		c.maxIterations = n
+	}
+}
+
+// RunTools sends params and, for as long as the model stops to use tools, executes the
+// requested tools with the registry and sends their results back, returning the first
+// message that does not request a tool. If params.Tools is empty, the registry's tools
+// are sent. The params are not modified.
+//
+// A handler error, including a call to an unregistered tool, either aborts the loop or
+// is fed back to the model, according to the registry's OnToolError policy or
+// WithOnToolError.
+func (s *MessagesService) RunTools(ctx context.Context, params *MessageParams, registry *ToolRegistry, opts ...RunToolsOption) (*Message, error) {
+	config := runToolsConfig{onToolError: registry.OnToolError, maxIterations: defaultMaxToolIterations}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	conversation := *params
+	conversation.Messages = append([]MessageParam(nil), params.Messages...)
+	if len(conversation.Tools) == 0 {
+		conversation.Tools = registry.Tools()
+	}
+
+	for i := 0; i < config.maxIterations; i++ {
+		message, err := s.Create(ctx, &conversation)
+		if err != nil {
+			return nil, err
+		}
+		if message.StopReason != "tool_use" {
+			return message, nil
+		}
+
+		var results []ContentBlock
+		for _, block := range message.Content {
+			if block.Type != "tool_use" || block.ToolCall == nil {
+				continue
+			}
+			output, err := registry.Call(ctx, block.ToolCall)
+			if err != nil {
+				if config.onToolError == ToolErrorAbort {
+					return nil, fmt.Errorf("tool %q failed: %w", block.ToolCall.Name, err)
+				}
+				s.client.log(ctx, LogLevelWarn, "tool failed, reporting error to model", "tool", block.ToolCall.Name, "error", err)
+				output = &ToolOutput{ToolCallID: block.ToolCall.ID, Output: err.Error(), IsError: true}
+			}
+			results = append(results, ToolResultContent(output.ToolCallID, output.Output, output.IsError))
+		}
+		if len(results) == 0 {
+			return message, nil
+		}
+		conversation.Messages = append(conversation.Messages, message.ToParam(), MessageParam{Role: string(RoleUser), Content: results})
+	}
+	return nil, fmt.Errorf("tool loop did not finish within %d iterations", config.maxIterations)
+}
+
+// AssistantToolUse builds an assistant turn containing a single tool_use block, for
+// example to construct few-shot tool examples. The input is marshaled to JSON; a
+// json.RawMessage is used verbatim, and a nil input is sent as {}. It returns an error
+// if input cannot be marshaled, such as a channel or a function.
+func AssistantToolUse(id, name string, input interface{}) (MessageParam, error) {
+	raw, ok := input.(json.RawMessage)
+	if !ok && input != nil {
+		var err error
+		raw, err = json.Marshal(input)
+		if err != nil {
+			return MessageParam{}, fmt.Errorf("error marshaling input for tool %q: %w", name, err)
+		}
+	}
+	if len(raw) == 0 || string(raw) == "null" {
+		raw = json.RawMessage("{}")
+	}
+	return MessageParam{Role: string(RoleAssistant), Content: []ContentBlock{ToolUseContent(id, name, raw)}}, nil
+}
+
+// NewToolResultMessage builds the user turn that answers the tool_use block with the given id.
+func NewToolResultMessage(toolUseID, output string) MessageParam {
+	return MessageParam{Role: string(RoleUser), Content: []ContentBlock{ToolResultContent(toolUseID, output, false)}}
+}
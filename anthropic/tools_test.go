@@ -0,0 +1,295 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type stockPriceArgs struct {
+	Ticker string `json:"ticker"`
+	Days   int    `json:"days"`
+}
+
+func TestToolRegistryRegisterTyped(t *testing.T) {
+	registry := NewToolRegistry()
+	err := RegisterTyped(registry, Tool{Name: "get_stock_price"}, func(ctx context.Context, args *stockPriceArgs) (string, error) {
+		return fmt.Sprintf("%s over %d days", args.Ticker, args.Days), nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+
+	testCases := []struct {
+		name        string
+		input       string
+		expected    string
+		expectedErr string
+	}{
+		{name: "Valid input", input: `{"ticker":"^GSPC","days":5}`, expected: "^GSPC over 5 days"},
+		{name: "Empty input", input: ``, expected: " over 0 days"},
+		{name: "Unknown field", input: `{"ticker":"^GSPC","symbol":"x"}`, expectedErr: `invalid input for tool "get_stock_price"`},
+		{name: "Wrong type", input: `{"days":"five"}`, expectedErr: `invalid input for tool "get_stock_price"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			call := &ToolCall{ID: "toolu_1", Name: "get_stock_price", Input: json.RawMessage(tc.input)}
+			output, err := registry.Call(context.Background(), call)
+			if tc.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.expectedErr) {
+					t.Fatalf("Expected error containing %q, got %v", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if output.ToolCallID != "toolu_1" || output.Output != tc.expected {
+				t.Errorf("Expected output %q for toolu_1, got %+v", tc.expected, output)
+			}
+		})
+	}
+}
+
+func TestToolRegistry(t *testing.T) {
+	registry := NewToolRegistry()
+	echo := func(ctx context.Context, input json.RawMessage) (string, error) { return string(input), nil }
+
+	if err := registry.Register(Tool{Name: "b"}, echo); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	if err := registry.Register(Tool{Name: "a"}, echo); err != nil {
+		t.Fatalf("Failed to register tool: %v", err)
+	}
+	if err := registry.Register(Tool{Name: "a"}, echo); err == nil {
+		t.Error("Expected an error registering a duplicate tool")
+	}
+	if err := registry.Register(Tool{}, echo); err == nil {
+		t.Error("Expected an error registering a tool without a name")
+	}
+
+	tools := registry.Tools()
+	if len(tools) != 2 || tools[0].Name != "b" || tools[1].Name != "a" {
+		t.Errorf("Expected tools in registration order [b a], got %+v", tools)
+	}
+
+	if _, err := registry.Call(context.Background(), &ToolCall{Name: "missing"}); err == nil {
+		t.Error("Expected an error calling an unregistered tool")
+	}
+}
+
+// mustAssistantToolUse calls AssistantToolUse, failing the test on error.
+func mustAssistantToolUse(t *testing.T, id, name string, input interface{}) MessageParam {
+	t.Helper()
+	turn, err := AssistantToolUse(id, name, input)
+	if err != nil {
+		t.Fatalf("AssistantToolUse failed: %v", err)
+	}
+	return turn
+}
+
+func TestAssistantToolUse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    interface{}
+		expected string
+	}{
+		{name: "Struct input", input: stockPriceArgs{Ticker: "^GSPC", Days: 5}, expected: `{"ticker":"^GSPC","days":5}`},
+		{name: "Map input", input: map[string]string{"ticker": "^GSPC"}, expected: `{"ticker":"^GSPC"}`},
+		{name: "Raw input", input: json.RawMessage(`{"ticker": "^GSPC"}`), expected: `{"ticker": "^GSPC"}`},
+		{name: "Nil input", input: nil, expected: `{}`},
+		{name: "Nil raw input", input: json.RawMessage(nil), expected: `{}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			turn := mustAssistantToolUse(t, "toolu_1", "get_stock_price", tc.input)
+			if turn.Role != "assistant" || len(turn.Content) != 1 || turn.Content[0].Type != "tool_use" {
+				t.Fatalf("Expected a single assistant tool_use block, got %+v", turn)
+			}
+			call := turn.Content[0].ToolCall
+			if call.ID != "toolu_1" || call.Name != "get_stock_price" || string(call.Input) != tc.expected {
+				t.Errorf("Expected call toolu_1/get_stock_price with input %s, got %+v (input %s)", tc.expected, call, call.Input)
+			}
+		})
+	}
+
+	if _, err := AssistantToolUse("toolu_1", "bad", make(chan int)); err == nil || !strings.Contains(err.Error(), `tool "bad"`) {
+		t.Errorf("Expected an error for an unmarshalable input, got %v", err)
+	}
+}
+
+func TestNewToolResultMessage(t *testing.T) {
+	params := &MessageParams{Messages: []MessageParam{
+		{Role: "user", Content: []ContentBlock{{Type: "text", Text: "What's the S&P 500 at?"}}},
+		mustAssistantToolUse(t, "toolu_1", "get_stock_price", stockPriceArgs{Ticker: "^GSPC"}),
+		NewToolResultMessage("toolu_1", "4,000.00"),
+	}}
+	if err := params.Validate(); err != nil {
+		t.Fatalf("Expected the few-shot conversation to be valid, got %v", err)
+	}
+
+	result := params.Messages[2]
+	if result.Role != "user" || result.Content[0].Type != "tool_result" || result.Content[0].ToolOutput.ToolCallID != "toolu_1" || result.Content[0].ToolOutput.Output != "4,000.00" {
+		t.Errorf("Unexpected tool result turn: %+v", result)
+	}
+}
+
+// toolLoopResponses answers the first request with a tool_use response and every
+// following request with a final text response, recording each request's messages
+// and, if bodies is not nil, its raw body.
+func toolLoopResponses(t *testing.T, requests *[]MessageParams, bodies *[][]byte) func(r *http.Request) testResponse {
+	return func(r *http.Request) testResponse {
+		body, _ := io.ReadAll(r.Body)
+		var params MessageParams
+		if err := json.Unmarshal(body, &params); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		*requests = append(*requests, params)
+		if bodies != nil {
+			*bodies = append(*bodies, body)
+		}
+
+		if len(*requests) == 1 {
+			return testResponse{body: `{"id":"msg_1","role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"get_stock_price","input":{"ticker":"^GSPC"}}],"stop_reason":"tool_use"}`}
+		}
+		return testResponse{body: `{"id":"msg_2","role":"assistant","content":[{"type":"text","text":"Done."}],"stop_reason":"end_turn"}`}
+	}
+}
+
+func TestMessagesService_RunToolsErrorPolicy(t *testing.T) {
+	failing := func(ctx context.Context, args *stockPriceArgs) (string, error) {
+		return "", fmt.Errorf("quote service unavailable")
+	}
+
+	testCases := []struct {
+		name           string
+		registryPolicy ToolErrorPolicy
+		opts           []RunToolsOption
+		expectErr      bool
+	}{
+		{name: "Abort by default", expectErr: true},
+		{name: "Feedback from registry", registryPolicy: ToolErrorFeedback},
+		{name: "Feedback per call", opts: []RunToolsOption{WithOnToolError(ToolErrorFeedback)}},
+		{name: "Abort per call overrides registry", registryPolicy: ToolErrorFeedback, opts: []RunToolsOption{WithOnToolError(ToolErrorAbort)}, expectErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests []MessageParams
+			server := newTestServer(t, toolLoopResponses(t, &requests, nil))
+			defer server.Close()
+			client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+			registry := NewToolRegistry()
+			registry.OnToolError = tc.registryPolicy
+			if err := RegisterTyped(registry, Tool{Name: "get_stock_price"}, failing); err != nil {
+				t.Fatalf("Failed to register tool: %v", err)
+			}
+			params := &MessageParams{
+				Model:    string(ModelSonnet),
+				Messages: []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Price?"}}}},
+			}
+
+			message, err := client.Messages().RunTools(context.Background(), params, registry, tc.opts...)
+			if tc.expectErr {
+				if err == nil || !strings.Contains(err.Error(), "quote service unavailable") {
+					t.Fatalf("Expected the handler error, got %v", err)
+				}
+				if len(requests) != 1 {
+					t.Errorf("Expected the loop to stop after the first request, got %d requests", len(requests))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if message.Text() != "Done." {
+				t.Errorf("Expected the final message, got %q", message.Text())
+			}
+			if len(requests) != 2 {
+				t.Fatalf("Expected 2 requests, got %d", len(requests))
+			}
+			result := requests[1].Messages[2].Content[0].ToolOutput
+			if result == nil || !result.IsError || result.ToolCallID != "toolu_1" || !strings.Contains(result.Output, "quote service unavailable") {
+				t.Errorf("Expected an is_error tool result for toolu_1, got %+v", result)
+			}
+			if len(params.Messages) != 1 {
+				t.Errorf("Expected the caller's params to be left unchanged")
+			}
+		})
+	}
+}
+
+func TestMessagesService_RunTools(t *testing.T) {
+	var requests []MessageParams
+	var bodies [][]byte
+	server := newTestServer(t, toolLoopResponses(t, &requests, &bodies))
+	defer server.Close()
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+
+	registry := NewToolRegistry()
+	_ = RegisterTyped(registry, Tool{Name: "get_stock_price"}, func(ctx context.Context, args *stockPriceArgs) (string, error) {
+		return args.Ticker + " is at 4,000.00", nil
+	})
+	params := &MessageParams{
+		Model:    string(ModelSonnet),
+		Messages: []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Price?"}}}},
+	}
+
+	message, err := client.Messages().RunTools(context.Background(), params, registry)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if message.ID != "msg_2" {
+		t.Errorf("Expected the final message msg_2, got %s", message.ID)
+	}
+	if len(requests[0].Tools) != 1 || requests[0].Tools[0].Name != "get_stock_price" {
+		t.Errorf("Expected the registry's tools to be sent, got %+v", requests[0].Tools)
+	}
+	result := requests[1].Messages[2].Content[0].ToolOutput
+	if result == nil || result.IsError || result.Output != "^GSPC is at 4,000.00" {
+		t.Errorf("Expected a successful tool result, got %+v", result)
+	}
+
+	// The follow-up must use the API's wire format for tool_use and tool_result blocks.
+	var sent struct {
+		Messages json.RawMessage `json:"messages"`
+	}
+	if err := json.Unmarshal(bodies[1], &sent); err != nil {
+		t.Fatalf("Failed to decode the follow-up request: %v", err)
+	}
+	expected := `[
+		{"role":"user","content":[{"type":"text","text":"Price?"}]},
+		{"role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"get_stock_price","input":{"ticker":"^GSPC"}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"toolu_1","content":"^GSPC is at 4,000.00"}]}
+	]`
+	if !jsonEqual(t, sent.Messages, []byte(expected)) {
+		t.Errorf("Expected follow-up messages %s, got %s", expected, sent.Messages)
+	}
+
+	requests = nil
+	_, err = client.Messages().RunTools(context.Background(), params, registry, WithMaxToolIterations(1))
+	if err == nil || !strings.Contains(err.Error(), "did not finish") {
+		t.Errorf("Expected an iteration limit error, got %v", err)
+	}
+}
+
+// jsonEqual reports whether a and b hold equal JSON values.
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -2,63 +2,166 @@ package anthropic
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"strings"
+	"time"
 )
 
-// parseStreamingMessageResponse handles the parsing of streaming message responses.
-func parseStreamingMessageResponse(ctx context.Context, r io.Reader, payload *MessageParams) (*Message, error) {
+// ParseMessageStream assembles a Message from a server-sent event stream read from r,
+// such as a pre-recorded stream or a response body obtained outside of the client.
+// The handler, if non-nil, is invoked with each streamed content chunk.
+//
+// Callbacks run synchronously on the goroutine that scans r, so the next event is not
+// read until they return. A slow callback therefore slows reading from r, bounding
+// buffering to the scanner's buffer instead of queueing events in memory.
+func ParseMessageStream(ctx context.Context, r io.Reader, handler StreamFunc) (*Message, error) {
+	payload, opts := &MessageParams{StreamFunc: handler}, callOptions{}
+	if config, ok := ctx.Value(streamConfigKey{}).(streamConfig); ok {
+		ctx, payload, opts = config.ctx, config.payload, config.opts
+	}
+	if payload.StreamErrorMode == StreamErrorContinue && payload.StreamFunc != nil {
+		payload = withSilencedStreamErrors(payload)
+	}
 	scanner := bufio.NewScanner(r)
+	if opts.streamSplit != nil {
+		scanner.Split(opts.streamSplit)
+	} else {
+		scanner.Split(scanSSELines)
+	}
 	eventChan := make(chan MessageEvent)
 
 	go func() {
 		defer close(eventChan)
 		var response Message
+		var eventName string
 		for scanner.Scan() {
 			line := scanner.Text()
 
-			if line == "" || !strings.HasPrefix(line, "data:") {
+			if line == "" {
+				eventName = ""
+				continue
+			}
+			if strings.HasPrefix(line, "event:") {
+				eventName = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+				continue
+			}
+			if !strings.HasPrefix(line, "data:") {
 				continue
 			}
-			data := strings.TrimPrefix(line, "data: ")
+			// A single space after the colon is optional.
+			data := strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+			if payload.RawEventFunc != nil {
+				if err := payload.RawEventFunc(ctx, eventName, []byte(data)); err != nil {
+					eventChan <- MessageEvent{Response: nil, Err: fmt.Errorf("raw event func returned an error: %w", err)}
+					return
+				}
+			}
 			event, err := parseStreamEvent(data)
 			if err != nil {
+				// A read error makes the scanner emit the partial line it buffered;
+				// report the read error rather than the truncated event.
+				if scanErr := scanner.Err(); scanErr != nil {
+					eventChan <- scanErrorEvent(ctx, scanErr, response)
+					return
+				}
 				eventChan <- MessageEvent{Response: nil, Err: fmt.Errorf("failed to parse stream event: %w", err)}
 				return
 			}
-			response, err = processStreamEvent(ctx, event, payload, response, eventChan)
+			response, err = processStreamEvent(ctx, event, payload, opts, response, eventChan)
 			if err != nil {
-				eventChan <- MessageEvent{Response: nil, Err: fmt.Errorf("failed to process stream event: %w", err)}
+				failed := MessageEvent{Response: nil, Err: fmt.Errorf("failed to process stream event: %w", err)}
+				// A callback that stops the stream because the context is done still
+				// gets the partially assembled message, as on a cancelled read.
+				if ctx.Err() != nil {
+					failed.Response = &response
+				}
+				eventChan <- failed
 				return
 			}
 		}
 		if err := scanner.Err(); err != nil {
-			eventChan <- MessageEvent{Response: nil, Err: fmt.Errorf("issue scanning response: %w", err)}
+			eventChan <- scanErrorEvent(ctx, err, response)
 		}
 	}()
 
 	var lastResponse *Message
 	for event := range eventChan {
 		if event.Err != nil {
-			return nil, event.Err
+			return event.Response, event.Err
 		}
 		lastResponse = event.Response
 	}
 	return lastResponse, nil
 }
 
+// streamConfigKey is the context key under which parseStreamingMessageResponse hands
+// the request's callbacks and options to ParseMessageStream.
+type streamConfigKey struct{}
+
+// streamConfig is the configuration of a stream parsed for a request. ctx is the
+// caller's context without the config, so that callbacks do not see it.
+type streamConfig struct {
+	ctx     context.Context
+	payload *MessageParams
+	opts    callOptions
+}
+
+// parseStreamingMessageResponse parses the streaming response to a request made with
+// payload, invoking all of its callbacks rather than only StreamFunc.
+func parseStreamingMessageResponse(ctx context.Context, r io.Reader, payload *MessageParams, opts callOptions) (*Message, error) {
+	config := streamConfig{ctx: ctx, payload: payload, opts: opts}
+	return ParseMessageStream(context.WithValue(ctx, streamConfigKey{}, config), r, payload.StreamFunc)
+}
+
+// scanErrorEvent builds the event reporting a failure to read the stream. If the
+// failure was caused by ctx being cancelled or timing out, the event carries the
+// response assembled so far, so that callers can keep the partial output.
+func scanErrorEvent(ctx context.Context, err error, response Message) MessageEvent {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return MessageEvent{Response: &response, Err: fmt.Errorf("issue scanning response: %w", ctxErr)}
+	}
+	return MessageEvent{Response: nil, Err: fmt.Errorf("issue scanning response: %w", classifyTransportError(ctx, err))}
+}
+
+// withSilencedStreamErrors returns a copy of payload whose StreamFunc stops being
+// invoked after it first returns an error, instead of propagating the error.
+func withSilencedStreamErrors(payload *MessageParams) *MessageParams {
+	silenced := *payload
+	streamFunc := payload.StreamFunc
+	failed := false
+	silenced.StreamFunc = func(ctx context.Context, chunk []byte) error {
+		if failed {
+			return nil
+		}
+		if err := streamFunc(ctx, chunk); err != nil {
+			failed = true
+		}
+		return nil
+	}
+	return &silenced
+}
+
 // parseStreamEvent parses a single stream event from JSON data.
+// Numbers are decoded as json.Number so that large integers in tool inputs keep their precision.
 func parseStreamEvent(data string) (map[string]interface{}, error) {
 	var event map[string]interface{}
-	err := json.Unmarshal([]byte(data), &event)
+	err := decodeJSONUseNumber([]byte(data), &event)
 	return event, err
 }
 
+// decodeJSONUseNumber unmarshals data into v, decoding numbers as json.Number.
+func decodeJSONUseNumber(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
 // processStreamEvent handles different types of stream events and updates the response accordingly.
-func processStreamEvent(ctx context.Context, event map[string]interface{}, payload *MessageParams, response Message, eventChan chan<- MessageEvent) (Message, error) {
+func processStreamEvent(ctx context.Context, event map[string]interface{}, payload *MessageParams, opts callOptions, response Message, eventChan chan<- MessageEvent) (Message, error) {
 	eventType, ok := event["type"].(string)
 	if !ok {
 		return response, fmt.Errorf("invalid event type")
@@ -71,16 +174,59 @@ func processStreamEvent(ctx context.Context, event map[string]interface{}, paylo
 	case "content_block_delta":
 		return handleContentBlockDeltaEvent(ctx, event, payload, response)
 	case "content_block_stop":
-		// Nothing to do here
+		return handleContentBlockStopEvent(ctx, event, payload, response)
 	case "message_delta":
 		return handleMessageDeltaEvent(event, response)
 	case "message_stop":
-		// Nothing to do here
+		if !opts.partialToolInput {
+			if err := checkToolInputs(response); err != nil {
+				return response, err
+			}
+		}
 		eventChan <- MessageEvent{Response: &response, Err: nil}
 	case "ping":
 		// Nothing to do here
 	default:
-		fmt.Printf("unknown event type: %s\n", eventType)
+		if opts.logger != nil {
+			opts.logger.Log(ctx, LogLevelDebug, "unknown stream event type", "type", eventType)
+		}
+	}
+	return response, nil
+}
+
+// checkToolInputs returns an *IncompleteToolInputError for the first tool call whose
+// accumulated input is not valid JSON.
+func checkToolInputs(response Message) error {
+	for _, block := range response.Content {
+		if block.ToolCall == nil || len(block.ToolCall.Input) == 0 {
+			continue
+		}
+		if !json.Valid(block.ToolCall.Input) {
+			return &IncompleteToolInputError{
+				ToolUseID:   block.ToolCall.ID,
+				ToolName:    block.ToolCall.Name,
+				PartialJSON: string(block.ToolCall.Input),
+			}
+		}
+	}
+	return nil
+}
+
+// handleContentBlockStopEvent passes the completed content block to the payload's
+// BlockCompleteFunc, if set.
+func handleContentBlockStopEvent(ctx context.Context, event map[string]interface{}, payload *MessageParams, response Message) (Message, error) {
+	if payload.BlockCompleteFunc == nil {
+		return response, nil
+	}
+	index, ok := getInt(event, "index")
+	if !ok {
+		return response, fmt.Errorf("invalid index field")
+	}
+	if index < 0 || index >= len(response.Content) {
+		return response, fmt.Errorf("content_block_stop for unknown content block %d", index)
+	}
+	if err := payload.BlockCompleteFunc(ctx, index, response.Content[index]); err != nil {
+		return response, err
 	}
 	return response, nil
 }
@@ -96,73 +242,117 @@ func handleMessageStartEvent(event map[string]interface{}, response Message) (Me
 		return response, fmt.Errorf("invalid usage field")
 	}
 
-	inputTokens, ok := usage["input_tokens"].(float64)
+	inputTokens, ok := getInt(usage, "input_tokens")
 	if !ok {
 		return response, fmt.Errorf("invalid input_tokens field")
 	}
 
 	response.ID = getString(message, "id")
 	response.Model = getString(message, "model")
+	// The response to a messages request is always an assistant turn.
 	response.Role = getString(message, "role")
+	if response.Role == "" {
+		response.Role = "assistant"
+	}
 	response.Type = getString(message, "type")
-	response.Usage.InputTokens = int(inputTokens)
+	response.Usage.InputTokens = inputTokens
+	if cacheCreation, ok := getInt(usage, "cache_creation_input_tokens"); ok {
+		response.Usage.CacheCreationInputTokens = cacheCreation
+	}
+	if cacheRead, ok := getInt(usage, "cache_read_input_tokens"); ok {
+		response.Usage.CacheReadInputTokens = cacheRead
+	}
 
 	return response, nil
 }
 
+// handleContentBlockStartEvent adds the block started by the event at its index. A
+// start event for an index that already holds a block, as sent by proxies that
+// replay events, replaces that block and discards the content accumulated for it,
+// so that replayed deltas are not applied twice.
 func handleContentBlockStartEvent(event map[string]interface{}, response Message) (Message, error) {
-	indexValue, ok := event["index"].(float64)
-	if !ok {
+	index, ok := getInt(event, "index")
+	if !ok || index < 0 {
 		return response, fmt.Errorf("invalid index field")
 	}
-	index := int(indexValue)
 
 	contentBlock, ok := event["content_block"].(map[string]interface{})
 	if !ok {
 		return response, fmt.Errorf("invalid content_block field")
 	}
 
+	var block ContentBlock
 	contentType := getString(contentBlock, "type")
 	switch contentType {
 	case "text":
-		if len(response.Content) <= index {
-			response.Content = append(response.Content, ContentBlock{
-				Type: contentType,
-			})
-		}
-	case "tool_use":
-		toolUse := &ToolCall{
+		block = TextContent("")
+	case "thinking":
+		block = ThinkingContent(getString(contentBlock, "thinking"), getString(contentBlock, "signature"))
+	case "redacted_thinking":
+		block = ContentBlock{
 			Type: contentType,
-			ID:   getString(contentBlock, "id"),
-			Name: getString(contentBlock, "name"),
+			Data: getString(contentBlock, "data"),
 		}
-		if input, ok := contentBlock["input"]; ok {
-			inputJSON, err := json.Marshal(input)
+	case "tool_use", "server_tool_use":
+		var input json.RawMessage
+		if value, ok := contentBlock["input"]; ok {
+			inputJSON, err := json.Marshal(value)
 			if err != nil {
 				return response, fmt.Errorf("failed to marshal tool call input: %w", err)
 			}
-			toolUse.Input = json.RawMessage(inputJSON)
+			input = json.RawMessage(inputJSON)
+		}
+		block = ToolUseContent(getString(contentBlock, "id"), getString(contentBlock, "name"), input)
+		// Server tool calls share the tool_use shape under their own type.
+		block.Type = contentType
+		block.ToolCall.Type = contentType
+	case "web_search_tool_result":
+		block = ContentBlock{Type: contentType, ToolUseID: getString(contentBlock, "tool_use_id")}
+		if content, ok := contentBlock["content"]; ok {
+			contentJSON, err := json.Marshal(content)
+			if err != nil {
+				return response, fmt.Errorf("failed to marshal web search result content: %w", err)
+			}
+			block.Content = json.RawMessage(contentJSON)
 		}
-		response.Content = append(response.Content, ContentBlock{Type: contentType, ToolCall: toolUse})
 	case "tool_result":
 		toolResult := &ToolOutput{
-			ToolCallID: getString(contentBlock, "tool_call_id"),
+			ToolCallID: getString(contentBlock, "tool_use_id"),
 			Output:     getString(contentBlock, "output"),
 		}
-		response.Content = append(response.Content, ContentBlock{Type: contentType, ToolOutput: toolResult})
+		if toolResult.ToolCallID == "" {
+			toolResult.ToolCallID = getString(contentBlock, "tool_call_id")
+		}
+		if content, ok := contentBlock["content"].(string); ok {
+			toolResult.Output = content
+		}
+		if content, ok := contentBlock["content"].([]interface{}); ok {
+			contentJSON, err := json.Marshal(content)
+			if err != nil {
+				return response, fmt.Errorf("failed to marshal tool result content: %w", err)
+			}
+			if err := json.Unmarshal(contentJSON, &toolResult.Content); err != nil {
+				return response, fmt.Errorf("failed to unmarshal tool result content: %w", err)
+			}
+		}
+		block = ContentBlock{Type: contentType, ToolOutput: toolResult}
 	default:
 		return response, fmt.Errorf("unknown content block type: %s", contentType)
 	}
 
+	if index >= 0 && index < len(response.Content) {
+		response.Content[index] = block
+	} else {
+		response.Content = append(response.Content, block)
+	}
 	return response, nil
 }
 
 func handleContentBlockDeltaEvent(ctx context.Context, event map[string]interface{}, payload *MessageParams, response Message) (Message, error) {
-	indexValue, ok := event["index"].(float64)
-	if !ok {
+	index, ok := getInt(event, "index")
+	if !ok || index < 0 {
 		return response, fmt.Errorf("invalid index field")
 	}
-	index := int(indexValue)
 
 	delta, ok := event["delta"].(map[string]interface{})
 	if !ok {
@@ -174,20 +364,59 @@ func handleContentBlockDeltaEvent(ctx context.Context, event map[string]interfac
 	case "text_delta":
 		text := getString(delta, "text")
 		if len(response.Content) <= index {
-			response.Content = append(response.Content, ContentBlock{
-				Type: "text",
-				Text: text,
-			})
+			// A stream may skip indexes; the skipped blocks stay empty so that this
+			// one keeps its index.
+			for len(response.Content) < index {
+				response.Content = append(response.Content, ContentBlock{})
+			}
+			response.Content = append(response.Content, TextContent(text))
 		} else {
 			response.Content[index].Text += text
 		}
+		if logprobs, ok := delta["logprobs"]; ok {
+			parsed, err := parseLogprobs(logprobs)
+			if err != nil {
+				return response, fmt.Errorf("invalid logprobs field: %w", err)
+			}
+			response.Content[index].Logprobs = append(response.Content[index].Logprobs, parsed...)
+		}
+	case "thinking_delta":
+		if len(response.Content) <= index || response.Content[index].Type != "thinking" {
+			return response, fmt.Errorf("invalid thinking_delta: no corresponding thinking block")
+		}
+		response.Content[index].Thinking += getString(delta, "thinking")
+	case "signature_delta":
+		if len(response.Content) <= index || response.Content[index].Type != "thinking" {
+			return response, fmt.Errorf("invalid signature_delta: no corresponding thinking block")
+		}
+		response.Content[index].Signature += getString(delta, "signature")
+	case "input_json_delta":
+		if len(response.Content) <= index || response.Content[index].ToolCall == nil {
+			return response, fmt.Errorf("invalid input_json_delta: no corresponding tool_use block")
+		}
+		toolCall := response.Content[index].ToolCall
+		fragment := getString(delta, "partial_json")
+		// The tool_use start event carries an empty input object that the partial
+		// JSON fragments replace. A tool called without arguments may get no
+		// fragments, or only empty ones, and keeps the empty object.
+		if fragment != "" {
+			if string(toolCall.Input) == "{}" {
+				toolCall.Input = nil
+			}
+			toolCall.Input = append(toolCall.Input, fragment...)
+		}
+		if payload.ToolInputDeltaFunc != nil {
+			if err := payload.ToolInputDeltaFunc(ctx, toolCall.ID, []byte(fragment)); err != nil {
+				return response, fmt.Errorf("tool input delta func returned an error: %w", err)
+			}
+		}
 	case "tool_use_delta":
 		if len(response.Content) <= index || response.Content[index].ToolCall == nil {
 			return response, fmt.Errorf("invalid tool_use_delta: no corresponding tool_use block")
 		}
 		if input, ok := delta["input"].(map[string]interface{}); ok {
 			var existingInput map[string]interface{}
-			err := json.Unmarshal(response.Content[index].ToolCall.Input, &existingInput)
+			err := decodeJSONUseNumber(response.Content[index].ToolCall.Input, &existingInput)
 			if err != nil {
 				return response, fmt.Errorf("failed to unmarshal existing input: %w", err)
 			}
@@ -212,15 +441,15 @@ func handleContentBlockDeltaEvent(ctx context.Context, event map[string]interfac
 		return response, fmt.Errorf("unknown delta type: %s", deltaType)
 	}
 
-	if payload.IsStreaming() {
+	if payload.StreamFunc != nil {
 		var streamContent []byte
 		switch deltaType {
 		case "text_delta":
-			streamContent = []byte(delta["text"].(string))
+			streamContent = []byte(getString(delta, "text"))
 		case "tool_call_delta":
 			streamContent, _ = json.Marshal(delta)
 		case "tool_output_delta":
-			streamContent = []byte(delta["output"].(string))
+			streamContent = []byte(getString(delta, "output"))
 		}
 		err := payload.StreamFunc(ctx, streamContent)
 		if err != nil {
@@ -237,18 +466,58 @@ func handleMessageDeltaEvent(event map[string]interface{}, response Message) (Me
 		return response, fmt.Errorf("invalid delta field")
 	}
 	response.StopReason = getString(delta, "stop_reason")
+	if stopSequence := getString(delta, "stop_sequence"); stopSequence != "" {
+		response.StopSequence = stopSequence
+	}
+	if container, ok := delta["container"].(map[string]interface{}); ok {
+		response.Container = &Container{ID: getString(container, "id")}
+		if expiresAt := getString(container, "expires_at"); expiresAt != "" {
+			t, err := time.Parse(time.RFC3339, expiresAt)
+			if err != nil {
+				return response, fmt.Errorf("invalid container expires_at field: %w", err)
+			}
+			response.Container.ExpiresAt = t
+		}
+	}
 
 	usage, ok := event["usage"].(map[string]interface{})
 	if !ok {
 		return response, fmt.Errorf("invalid usage field")
 	}
-	outputTokens, ok := usage["output_tokens"].(float64)
-	if ok {
-		response.Usage.OutputTokens = int(outputTokens)
+	// Usage in message_delta is cumulative; fields that are present replace the
+	// values reported by message_start.
+	if outputTokens, ok := getInt(usage, "output_tokens"); ok {
+		response.Usage.OutputTokens = outputTokens
+	}
+	if inputTokens, ok := getInt(usage, "input_tokens"); ok {
+		response.Usage.InputTokens = inputTokens
+	}
+	if cacheCreation, ok := getInt(usage, "cache_creation_input_tokens"); ok {
+		response.Usage.CacheCreationInputTokens = cacheCreation
+	}
+	if cacheRead, ok := getInt(usage, "cache_read_input_tokens"); ok {
+		response.Usage.CacheReadInputTokens = cacheRead
 	}
 	return response, nil
 }
 
+// getInt returns the integer value of a numeric field, which may have been
+// decoded either as float64 or as json.Number.
+func getInt(m map[string]interface{}, key string) (int, bool) {
+	switch value := m[key].(type) {
+	case float64:
+		return int(value), true
+	case json.Number:
+		n, err := value.Int64()
+		if err != nil {
+			return 0, false
+		}
+		return int(n), true
+	default:
+		return 0, false
+	}
+}
+
 func getString(m map[string]interface{}, key string) string {
 	value, ok := m[key].(string)
 	if !ok {
@@ -256,3 +525,42 @@ func getString(m map[string]interface{}, key string) string {
 	}
 	return value
 }
+
+// parseLogprobs converts the decoded logprobs of a text delta into TokenLogprobs.
+func parseLogprobs(value interface{}) ([]TokenLogprob, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var logprobs []TokenLogprob
+	if err := json.Unmarshal(data, &logprobs); err != nil {
+		return nil, err
+	}
+	return logprobs, nil
+}
+
+// scanSSELines is a bufio.SplitFunc that splits a server-sent event stream into
+// lines. Unlike bufio.ScanLines, it also accepts a lone "\r" as a line ending, as
+// the SSE format allows.
+func scanSSELines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		// A "\r" may be the first half of a "\r\n" split across reads.
+		if i+1 == len(data) && !atEOF {
+			return 0, nil, nil
+		}
+		if i+1 < len(data) && data[i+1] == '\n' {
+			return i + 2, data[:i], nil
+		}
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -3,10 +3,16 @@ package anthropic
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 func TestParseStreamingMessageResponse(t *testing.T) {
@@ -15,6 +21,9 @@ func TestParseStreamingMessageResponse(t *testing.T) {
 		input    string
 		expected *Message
 		hasError bool
+		// nonStreamingJSON is the equivalent non-streaming response body, checked
+		// with assertParity. It is empty for streams that fail to parse.
+		nonStreamingJSON string
 	}{
 		{
 			name: "Valid stream",
@@ -45,7 +54,8 @@ data: {"type":"message_stop"}
 					OutputTokens: 20,
 				},
 			},
-			hasError: false,
+			hasError:         false,
+			nonStreamingJSON: `{"id":"msg_123","role":"assistant","model":"claude-3-sonnet-20240229","content":[{"type":"text","text":"Hello, world!"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":20}}`,
 		},
 		{
 			name:     "Invalid JSON",
@@ -63,7 +73,7 @@ data: {"type":"message_stop"}
 					return nil
 				},
 			}
-			result, err := parseStreamingMessageResponse(context.Background(), reader, params)
+			result, err := parseStreamingMessageResponse(context.Background(), reader, params, callOptions{})
 
 			if tc.hasError && err == nil {
 				t.Errorf("Expected an error, but got none")
@@ -74,6 +84,9 @@ data: {"type":"message_stop"}
 			if !reflect.DeepEqual(result, tc.expected) {
 				t.Errorf("Expected %+v, but got %+v", tc.expected, result)
 			}
+			if tc.nonStreamingJSON != "" {
+				assertParity(t, streamEventData(tc.input), tc.nonStreamingJSON)
+			}
 		})
 	}
 }
@@ -214,7 +227,7 @@ func TestProcessStreamEvent(t *testing.T) {
 			}
 			eventChan := make(chan MessageEvent, 1)
 
-			result, err := processStreamEvent(ctx, tc.event, payload, tc.response, eventChan)
+			result, err := processStreamEvent(ctx, tc.event, payload, callOptions{}, tc.response, eventChan)
 
 			if tc.hasError && err == nil {
 				t.Errorf("Expected an error, but got none")
@@ -260,6 +273,26 @@ func TestHandleMessageStartEvent(t *testing.T) {
 			},
 			hasError: false,
 		},
+		{
+			name: "Missing Role Defaults To Assistant",
+			event: map[string]interface{}{
+				"message": map[string]interface{}{
+					"id":    "msg_123",
+					"model": "claude-3-sonnet-20240229",
+					"usage": map[string]interface{}{
+						"input_tokens": float64(10),
+					},
+				},
+			},
+			response: Message{},
+			expected: Message{
+				ID:    "msg_123",
+				Model: "claude-3-sonnet-20240229",
+				Role:  "assistant",
+				Usage: Usage{InputTokens: 10},
+			},
+			hasError: false,
+		},
 		{
 			name: "Invalid Message Field",
 			event: map[string]interface{}{
@@ -473,6 +506,50 @@ func TestHandleMessageDeltaEvent(t *testing.T) {
 			expected: Message{},
 			hasError: true,
 		},
+		{
+			name: "Rich Message Delta Event",
+			event: map[string]interface{}{
+				"delta": map[string]interface{}{
+					"stop_reason":   "stop_sequence",
+					"stop_sequence": "\n\nHuman:",
+					"container": map[string]interface{}{
+						"id":         "container_123",
+						"expires_at": "2025-05-22T18:00:00Z",
+					},
+				},
+				"usage": map[string]interface{}{
+					"input_tokens":                json.Number("12"),
+					"output_tokens":               json.Number("40"),
+					"cache_creation_input_tokens": json.Number("100"),
+					"cache_read_input_tokens":     json.Number("900"),
+				},
+			},
+			response: Message{Usage: Usage{InputTokens: 10}},
+			expected: Message{
+				StopReason:   "stop_sequence",
+				StopSequence: "\n\nHuman:",
+				Container:    &Container{ID: "container_123", ExpiresAt: time.Date(2025, 5, 22, 18, 0, 0, 0, time.UTC)},
+				Usage: Usage{
+					InputTokens:              12,
+					OutputTokens:             40,
+					CacheCreationInputTokens: 100,
+					CacheReadInputTokens:     900,
+				},
+			},
+			hasError: false,
+		},
+		{
+			name: "Invalid Container Expiry",
+			event: map[string]interface{}{
+				"delta": map[string]interface{}{
+					"container": map[string]interface{}{"id": "container_123", "expires_at": "tomorrow"},
+				},
+				"usage": map[string]interface{}{},
+			},
+			response: Message{},
+			expected: Message{Container: &Container{ID: "container_123"}},
+			hasError: true,
+		},
 		{
 			name: "Invalid Usage Field",
 			event: map[string]interface{}{
@@ -546,10 +623,11 @@ func TestParseStreamingMessageResponseWithInvalidScanner(t *testing.T) {
 			return nil
 		},
 	}
-	_, err := parseStreamingMessageResponse(context.Background(), invalidReader, params)
+	_, err := parseStreamingMessageResponse(context.Background(), invalidReader, params, callOptions{})
 	if err == nil {
 		t.Errorf("Expected an error, but got none")
 	}
+	// No parity check: a stream that fails to read has no non-streaming equivalent.
 }
 
 // errorReader is a custom io.Reader that always returns an error.
@@ -588,7 +666,7 @@ func TestProcessStreamEventWithUnknownType(t *testing.T) {
 	response := Message{}
 	eventChan := make(chan MessageEvent, 1)
 
-	result, err := processStreamEvent(context.Background(), event, payload, response, eventChan)
+	result, err := processStreamEvent(context.Background(), event, payload, callOptions{}, response, eventChan)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -606,13 +684,17 @@ func TestParseStreamingMessageResponseWithMessageStopEvent(t *testing.T) {
 			return nil
 		},
 	}
-	result, err := parseStreamingMessageResponse(context.Background(), reader, params)
+	result, err := parseStreamingMessageResponse(context.Background(), reader, params, callOptions{})
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
 	if result == nil {
-		t.Errorf("Expected a non-nil result, but got nil")
+		t.Fatalf("Expected a non-nil result, but got nil")
+	}
+	if !reflect.DeepEqual(*result, Message{}) {
+		t.Errorf("Expected an empty message, got %+v", result)
 	}
+	// No parity check: a lone message_stop has no non-streaming counterpart.
 }
 
 func TestParseStreamingMessageResponseWithPingEvent(t *testing.T) {
@@ -624,13 +706,14 @@ func TestParseStreamingMessageResponseWithPingEvent(t *testing.T) {
 			return nil
 		},
 	}
-	result, err := parseStreamingMessageResponse(context.Background(), reader, params)
+	result, err := parseStreamingMessageResponse(context.Background(), reader, params, callOptions{})
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
 	if result != nil {
 		t.Errorf("Expected a nil result, but got %+v", result)
 	}
+	// No parity check: a stream without message_stop yields no message at all.
 }
 
 func TestHandleContentBlockDeltaEventWithNewContentBlock(t *testing.T) {
@@ -888,3 +971,616 @@ func assertContentBlocksEqual(t *testing.T, expected, actual ContentBlock) {
 		}
 	}
 }
+
+func TestParseStreamingMessageResponseWithRawEventFunc(t *testing.T) {
+	input := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_123","role":"assistant","usage":{"input_tokens":10}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}
+
+event: message_stop
+data: {"type":"message_stop"}
+`
+	type rawEvent struct {
+		name string
+		data string
+	}
+	var events []rawEvent
+	params := &MessageParams{
+		RawEventFunc: func(ctx context.Context, eventName string, data []byte) error {
+			events = append(events, rawEvent{name: eventName, data: string(data)})
+			return nil
+		},
+	}
+	if !params.IsStreaming() {
+		t.Fatalf("Expected RawEventFunc to enable streaming")
+	}
+
+	result, err := parseStreamingMessageResponse(context.Background(), strings.NewReader(input), params, callOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Content[0].Text != "Hi" {
+		t.Errorf("Expected assembled text 'Hi', got %+v", result.Content)
+	}
+
+	expected := []rawEvent{
+		{name: "message_start", data: `{"type":"message_start","message":{"id":"msg_123","role":"assistant","usage":{"input_tokens":10}}}`},
+		{name: "content_block_delta", data: `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}`},
+		{name: "message_stop", data: `{"type":"message_stop"}`},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Expected raw events %+v, got %+v", expected, events)
+	}
+}
+
+func TestParseStreamingMessageResponseWithRawEventFuncError(t *testing.T) {
+	input := "event: ping\ndata: {\"type\":\"ping\"}\n\n"
+	params := &MessageParams{
+		RawEventFunc: func(ctx context.Context, eventName string, data []byte) error {
+			return fmt.Errorf("capture failed")
+		},
+	}
+	_, err := parseStreamingMessageResponse(context.Background(), strings.NewReader(input), params, callOptions{})
+	if err == nil || !strings.Contains(err.Error(), "capture failed") {
+		t.Errorf("Expected raw event func error, got %v", err)
+	}
+}
+
+func TestParseStreamingMessageResponsePreservesLargeToolIntegers(t *testing.T) {
+	input := `data: {"type":"message_start","message":{"id":"msg_123","role":"assistant","usage":{"input_tokens":10}}}
+
+data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"call_123","name":"lookup_order","input":{"order_id":9007199254740993}}}
+
+data: {"type":"content_block_delta","index":0,"delta":{"type":"tool_use_delta","input":{"customer_id":9223372036854775807}}}
+
+data: {"type":"content_block_stop","index":0}
+
+data: {"type":"message_stop"}
+`
+	params := &MessageParams{
+		StreamFunc: func(ctx context.Context, chunk []byte) error { return nil },
+	}
+	result, err := parseStreamingMessageResponse(context.Background(), strings.NewReader(input), params, callOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var args struct {
+		OrderID    int64 `json:"order_id"`
+		CustomerID int64 `json:"customer_id"`
+	}
+	if err := json.Unmarshal(result.Content[0].ToolCall.Input, &args); err != nil {
+		t.Fatalf("Failed to unmarshal tool input %s: %v", string(result.Content[0].ToolCall.Input), err)
+	}
+	if args.OrderID != 9007199254740993 {
+		t.Errorf("Expected order_id 9007199254740993, got %d", args.OrderID)
+	}
+	if args.CustomerID != 9223372036854775807 {
+		t.Errorf("Expected customer_id 9223372036854775807, got %d", args.CustomerID)
+	}
+	assertParity(t, streamEventData(input), `{"id":"msg_123","role":"assistant","content":[{"type":"tool_use","id":"call_123","name":"lookup_order","input":{"customer_id":9223372036854775807,"order_id":9007199254740993}}],"usage":{"input_tokens":10}}`)
+}
+
+func TestParseMessageStream(t *testing.T) {
+	input := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_123","role":"assistant","model":"claude-3-sonnet-20240229","usage":{"input_tokens":10}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Replayed"}}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":3}}
+
+event: message_stop
+data: {"type":"message_stop"}
+`
+	var chunks []string
+	message, err := ParseMessageStream(context.Background(), strings.NewReader(input), func(ctx context.Context, chunk []byte) error {
+		chunks = append(chunks, string(chunk))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if message.ID != "msg_123" || message.StopReason != "end_turn" || message.Content[0].Text != "Replayed" {
+		t.Errorf("Unexpected message: %+v", message)
+	}
+	if !reflect.DeepEqual(chunks, []string{"Replayed"}) {
+		t.Errorf("Expected chunks [Replayed], got %v", chunks)
+	}
+
+	message, err = ParseMessageStream(context.Background(), strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error with nil handler: %v", err)
+	}
+	if message.Content[0].Text != "Replayed" {
+		t.Errorf("Unexpected content with nil handler: %+v", message.Content)
+	}
+}
+
+func TestParseStreamingMessageResponseStreamErrorMode(t *testing.T) {
+	input := `data: {"type":"message_start","message":{"id":"msg_123","role":"assistant","usage":{"input_tokens":10}}}
+
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text"}}
+
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"one "}}
+
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"two "}}
+
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"three"}}
+
+data: {"type":"message_stop"}
+`
+	testCases := []struct {
+		name          string
+		mode          StreamErrorMode
+		expectError   bool
+		expectedText  string
+		expectedCalls int
+	}{
+		{name: "Abort", mode: StreamErrorAbort, expectError: true, expectedCalls: 2},
+		{name: "Continue", mode: StreamErrorContinue, expectError: false, expectedText: "one two three", expectedCalls: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			params := &MessageParams{
+				StreamErrorMode: tc.mode,
+				StreamFunc: func(ctx context.Context, chunk []byte) error {
+					calls++
+					if calls == 2 {
+						return fmt.Errorf("client went away")
+					}
+					return nil
+				},
+			}
+
+			result, err := parseStreamingMessageResponse(context.Background(), strings.NewReader(input), params, callOptions{})
+			if tc.expectError {
+				if err == nil || !strings.Contains(err.Error(), "client went away") {
+					t.Errorf("Expected StreamFunc error, got %v", err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if result.Content[0].Text != tc.expectedText {
+					t.Errorf("Expected text %q, got %q", tc.expectedText, result.Content[0].Text)
+				}
+			}
+			if calls != tc.expectedCalls {
+				t.Errorf("Expected %d StreamFunc calls, got %d", tc.expectedCalls, calls)
+			}
+		})
+	}
+}
+
+func TestParseStreamingMessageResponseIncompleteToolInput(t *testing.T) {
+	input := `data: {"type":"message_start","message":{"id":"msg_123","role":"assistant","usage":{"input_tokens":10}}}
+
+data: {"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"search","input":{}}}
+
+data: {"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"query\": \"hel"}}
+
+data: {"type":"content_block_stop","index":0}
+
+data: {"type":"message_delta","delta":{"stop_reason":"max_tokens"},"usage":{"output_tokens":5}}
+
+data: {"type":"message_stop"}
+`
+	params := &MessageParams{
+		StreamFunc: func(ctx context.Context, chunk []byte) error { return nil },
+	}
+	_, err := parseStreamingMessageResponse(context.Background(), strings.NewReader(input), params, callOptions{})
+	if !errors.Is(err, ErrIncompleteToolInput) {
+		t.Fatalf("Expected ErrIncompleteToolInput, got %v", err)
+	}
+
+	var inputErr *IncompleteToolInputError
+	if !errors.As(err, &inputErr) {
+		t.Fatalf("Expected an *IncompleteToolInputError, got %T", err)
+	}
+	if inputErr.ToolName != "search" || inputErr.ToolUseID != "toolu_1" || inputErr.PartialJSON != `{"query": "hel` {
+		t.Errorf("Unexpected error fields: %+v", inputErr)
+	}
+}
+
+func TestParseStreamingMessageResponseNoArgumentTool(t *testing.T) {
+	testCases := []struct {
+		name   string
+		deltas []string
+	}{
+		{name: "No fragments"},
+		{name: "Empty fragments", deltas: []string{"", ""}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			events := []string{
+				`{"type":"message_start","message":{"id":"msg_123","role":"assistant","usage":{"input_tokens":10}}}`,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"now","input":{}}}`,
+			}
+			for _, fragment := range tc.deltas {
+				events = append(events, fmt.Sprintf(`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":%q}}`, fragment))
+			}
+			events = append(events,
+				`{"type":"content_block_stop","index":0}`,
+				`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":5}}`,
+				`{"type":"message_stop"}`,
+			)
+			var stream strings.Builder
+			for _, event := range events {
+				stream.WriteString("data: " + event + "\n\n")
+			}
+
+			params := &MessageParams{
+				StreamFunc: func(ctx context.Context, chunk []byte) error { return nil },
+			}
+			message, err := parseStreamingMessageResponse(context.Background(), strings.NewReader(stream.String()), params, callOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if input := string(message.Content[0].ToolCall.Input); input != "{}" {
+				t.Errorf("Expected input {}, got %q", input)
+			}
+			assertParity(t, events, `{"id":"msg_123","role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"now","input":{}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":5}}`)
+		})
+	}
+}
+
+// assertParity checks that assembling the given stream events produces the same
+// Message as decoding the equivalent non-streaming response body. Messages are
+// compared by their JSON encoding so that tool inputs are compared as JSON values
+// regardless of how the fragments were split.
+func assertParity(t *testing.T, events []string, nonStreamingJSON string) {
+	t.Helper()
+
+	var stream strings.Builder
+	for _, event := range events {
+		stream.WriteString("data: " + event + "\n\n")
+	}
+	params := &MessageParams{
+		StreamFunc: func(ctx context.Context, chunk []byte) error { return nil },
+	}
+	streamed, err := parseStreamingMessageResponse(context.Background(), strings.NewReader(stream.String()), params, callOptions{})
+	if err != nil {
+		t.Fatalf("Failed to parse stream: %v", err)
+	}
+
+	var decoded Message
+	if err := json.Unmarshal([]byte(nonStreamingJSON), &decoded); err != nil {
+		t.Fatalf("Failed to decode non-streaming response: %v", err)
+	}
+
+	streamedJSON, err := json.Marshal(streamed)
+	if err != nil {
+		t.Fatalf("Failed to marshal streamed message: %v", err)
+	}
+	decodedJSON, err := json.Marshal(&decoded)
+	if err != nil {
+		t.Fatalf("Failed to marshal decoded message: %v", err)
+	}
+	if string(streamedJSON) != string(decodedJSON) {
+		t.Errorf("Streaming and non-streaming messages differ:\nstreamed: %s\ndecoded:  %s", streamedJSON, decodedJSON)
+	}
+}
+
+// streamEventData returns the data payloads of the events in a raw event stream.
+func streamEventData(input string) []string {
+	var events []string
+	for _, line := range strings.Split(input, "\n") {
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			events = append(events, data)
+		}
+	}
+	return events
+}
+
+func TestStreamingParity(t *testing.T) {
+	testCases := []struct {
+		name             string
+		events           []string
+		nonStreamingJSON string
+	}{
+		{
+			name:             "Text",
+			events:           helloStreamEvents,
+			nonStreamingJSON: `{"id":"msg_123","role":"assistant","model":"claude-3-sonnet-20240229","content":[{"type":"text","text":"Hello, world!"}],"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":20}}`,
+		},
+		{
+			name: "Thinking and tool use",
+			events: []string{
+				`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-7-sonnet-20250219","usage":{"input_tokens":10,"cache_read_input_tokens":5}}}`,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Look it up."}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig_1"}}`,
+				`{"type":"content_block_stop","index":0}`,
+				`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"search","input":{}}}`,
+				`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"query\": "}}`,
+				`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"weather\", \"limit\": 3}"}}`,
+				`{"type":"content_block_stop","index":1}`,
+				`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":30}}`,
+				`{"type":"message_stop"}`,
+			},
+			nonStreamingJSON: `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-7-sonnet-20250219","content":[{"type":"thinking","thinking":"Look it up.","signature":"sig_1"},{"type":"tool_use","id":"toolu_1","name":"search","input":{"query":"weather","limit":3}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":30,"cache_read_input_tokens":5}}`,
+		},
+		{
+			name: "Stop sequence and container",
+			events: []string{
+				`{"type":"message_start","message":{"id":"msg_2","role":"assistant","model":"claude-3-7-sonnet-20250219","usage":{"input_tokens":10}}}`,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Done"}}`,
+				`{"type":"content_block_stop","index":0}`,
+				`{"type":"message_delta","delta":{"stop_reason":"stop_sequence","stop_sequence":"END","container":{"id":"container_1","expires_at":"2025-05-22T18:00:00Z"}},"usage":{"output_tokens":2,"cache_read_input_tokens":50}}`,
+				`{"type":"message_stop"}`,
+			},
+			nonStreamingJSON: `{"id":"msg_2","role":"assistant","model":"claude-3-7-sonnet-20250219","content":[{"type":"text","text":"Done"}],"stop_reason":"stop_sequence","stop_sequence":"END","container":{"id":"container_1","expires_at":"2025-05-22T18:00:00Z"},"usage":{"input_tokens":10,"output_tokens":2,"cache_read_input_tokens":50}}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assertParity(t, tc.events, tc.nonStreamingJSON)
+		})
+	}
+}
+
+func TestParseStreamingMessageResponseBackpressure(t *testing.T) {
+	const deltas = 100
+	reader, writer := io.Pipe()
+	var written atomic.Int64
+	go func() {
+		events := []string{`{"type":"message_start","message":{"id":"msg_123","role":"assistant","usage":{"input_tokens":10}}}`}
+		for i := 0; i < deltas; i++ {
+			events = append(events, fmt.Sprintf(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"%d "}}`, i))
+		}
+		events = append(events, `{"type":"message_stop"}`)
+		for _, event := range events {
+			// A pipe write blocks until the parser reads it, so written counts the
+			// events the parser has pulled from the stream.
+			if _, err := writer.Write([]byte("data: " + event + "\n\n")); err != nil {
+				return
+			}
+			written.Add(1)
+		}
+		writer.Close()
+	}()
+
+	var chunks []string
+	params := &MessageParams{
+		StreamFunc: func(ctx context.Context, chunk []byte) error {
+			time.Sleep(time.Millisecond)
+			// Besides message_start and the events already delivered, the parser may
+			// have read at most the event being delivered.
+			if ahead := written.Load() - 1 - int64(len(chunks)); ahead > 1 {
+				t.Errorf("Expected the parser to wait for the slow StreamFunc, but it read %d events ahead", ahead)
+			}
+			chunks = append(chunks, string(chunk))
+			return nil
+		},
+	}
+	message, err := parseStreamingMessageResponse(context.Background(), reader, params, callOptions{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(chunks) != deltas {
+		t.Fatalf("Expected %d chunks, got %d", deltas, len(chunks))
+	}
+	for i, chunk := range chunks {
+		if chunk != fmt.Sprintf("%d ", i) {
+			t.Fatalf("Expected chunk %d to be %q, got %q", i, fmt.Sprintf("%d ", i), chunk)
+		}
+	}
+	if message.Text() != strings.Join(chunks, "") {
+		t.Errorf("Expected the message to contain every chunk, got %q", message.Text())
+	}
+}
+
+func TestParseStreamingMessageResponseDuplicateContentBlockStart(t *testing.T) {
+	testCases := []struct {
+		name     string
+		events   []string
+		expected ContentBlock
+	}{
+		{
+			name: "Duplicate start before deltas",
+			events: []string{
+				`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+			},
+			expected: ContentBlock{Type: "text", Text: "Hello"},
+		},
+		{
+			name: "Replayed block",
+			events: []string{
+				`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`,
+			},
+			expected: ContentBlock{Type: "text", Text: "Hello"},
+		},
+		{
+			name: "Replayed tool use",
+			events: []string{
+				`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"search","input":{}}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"query\":"}}`,
+				`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_1","name":"search","input":{}}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"query\":"}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"weather\"}"}}`,
+			},
+			expected: ContentBlock{Type: "tool_use", ToolCall: &ToolCall{Type: "tool_use", ID: "toolu_1", Name: "search", Input: json.RawMessage(`{"query":"weather"}`)}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			events := append([]string{`{"type":"message_start","message":{"id":"msg_123","role":"assistant","usage":{"input_tokens":10}}}`}, tc.events...)
+			events = append(events, `{"type":"content_block_stop","index":0}`, `{"type":"message_stop"}`)
+			message, err := ParseMessageStream(context.Background(), strings.NewReader("data: "+strings.Join(events, "\n\ndata: ")+"\n\n"), nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(message.Content) != 1 {
+				t.Fatalf("Expected a single content block, got %d: %+v", len(message.Content), message.Content)
+			}
+			assertContentBlocksEqual(t, tc.expected, message.Content[0])
+		})
+	}
+}
+
+func TestScanSSELines(t *testing.T) {
+	testCases := []struct {
+		name      string
+		separator string
+	}{
+		{name: "LF", separator: "\n"},
+		{name: "CRLF", separator: "\r\n"},
+		{name: "CR", separator: "\r"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sb strings.Builder
+			for _, event := range helloStreamEvents {
+				// Omit the optional space after "data:" to check it is not required.
+				sb.WriteString("event: x" + tc.separator + "data:" + event + tc.separator + tc.separator)
+			}
+			// One byte at a time, so that CRLF pairs are split across reads.
+			message, err := ParseMessageStream(context.Background(), iotest.OneByteReader(strings.NewReader(sb.String())), nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if message.Text() != "Hello, world!" {
+				t.Errorf("Expected %q, got %q", "Hello, world!", message.Text())
+			}
+		})
+	}
+}
+
+func TestWithStreamSplitFunc(t *testing.T) {
+	// A gateway that frames each event as a 4-digit length followed by the data line.
+	var framed strings.Builder
+	for _, event := range helloStreamEvents {
+		line := "data: " + event
+		fmt.Fprintf(&framed, "%04d%s", len(line), line)
+	}
+	server := newTestServer(t, respondWith(testResponse{header: http.Header{"Content-Type": {"text/event-stream"}}, body: framed.String()}))
+	defer server.Close()
+
+	lengthPrefixed := func(data []byte, atEOF bool) (int, []byte, error) {
+		if len(data) < 4 {
+			if atEOF && len(data) > 0 {
+				return 0, nil, fmt.Errorf("truncated frame header")
+			}
+			return 0, nil, nil
+		}
+		var size int
+		if _, err := fmt.Sscanf(string(data[:4]), "%04d", &size); err != nil {
+			return 0, nil, err
+		}
+		if len(data) < 4+size {
+			if atEOF {
+				return 0, nil, fmt.Errorf("truncated frame")
+			}
+			return 0, nil, nil
+		}
+		return 4 + size, data[4 : 4+size], nil
+	}
+
+	client, err := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL), WithStreamSplitFunc(lengthPrefixed))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	message, err := client.Messages().Create(context.Background(), &MessageParams{
+		Model:      string(ModelSonnet),
+		StreamFunc: func(ctx context.Context, chunk []byte) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if message.Text() != "Hello, world!" {
+		t.Errorf("Expected %q, got %q", "Hello, world!", message.Text())
+	}
+
+	if _, err := NewClient(WithAPIKey("test-key"), WithStreamSplitFunc(nil)); err == nil {
+		t.Error("Expected an error for a nil split func")
+	}
+}
+
+func TestHandleContentBlockDeltaEventGappedIndex(t *testing.T) {
+	event := map[string]interface{}{
+		"index": float64(2),
+		"delta": map[string]interface{}{
+			"type":     "text_delta",
+			"text":     "Yes",
+			"logprobs": []interface{}{map[string]interface{}{"token": "Yes", "logprob": -0.01}},
+		},
+	}
+
+	result, err := handleContentBlockDeltaEvent(context.Background(), event, &MessageParams{}, Message{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(result.Content) != 3 {
+		t.Fatalf("Expected 3 content blocks, got %d", len(result.Content))
+	}
+	block := result.Content[2]
+	if block.Text != "Yes" || len(block.Logprobs) != 1 || block.Logprobs[0].Token != "Yes" {
+		t.Errorf("Expected text and logprobs at index 2, got %+v", block)
+	}
+}
+
+func TestParseMessageStreamMalformedEvents(t *testing.T) {
+	testCases := []struct {
+		name      string
+		events    []string
+		expectErr bool
+	}{
+		{
+			name: "Text delta without text",
+			events: []string{
+				`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+				`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta"}}`,
+				`{"type":"message_stop"}`,
+			},
+			expectErr: false,
+		},
+		{
+			name:      "Negative delta index",
+			events:    []string{`{"type":"content_block_delta","index":-1,"delta":{"type":"text_delta","text":"Hi"}}`},
+			expectErr: true,
+		},
+		{
+			name:      "Negative start index",
+			events:    []string{`{"type":"content_block_start","index":-1,"content_block":{"type":"text","text":""}}`},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stream strings.Builder
+			for _, event := range tc.events {
+				stream.WriteString("data: " + event + "\n\n")
+			}
+			handler := func(ctx context.Context, chunk []byte) error { return nil }
+			message, err := ParseMessageStream(context.Background(), strings.NewReader(stream.String()), handler)
+			if tc.expectErr && err == nil {
+				t.Errorf("Expected an error, got message %+v", message)
+			}
+			if !tc.expectErr && (err != nil || message == nil) {
+				t.Errorf("Expected a message, got %+v (error %v)", message, err)
+			}
+		})
+	}
+}
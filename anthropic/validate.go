@@ -0,0 +1,163 @@
+package anthropic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoMessages is returned when a request has a system prompt but no messages.
+// The API requires at least one user message; the system prompt alone is not enough.
+var ErrNoMessages = errors.New("at least one user message is required: the system prompt alone is not a valid request, add a user message to Messages")
+
+// ErrInvalidRole is returned, wrapped, when a message has a role other than RoleUser
+// or RoleAssistant.
+var ErrInvalidRole = errors.New("invalid message role")
+
+// Validate performs client-side checks on the params that would otherwise only
+// be reported by the server.
+func (p *MessageParams) Validate() error {
+	if p.System != "" && len(p.SystemBlocks) > 0 {
+		return fmt.Errorf("System and SystemBlocks are mutually exclusive")
+	}
+	if len(p.Messages) == 0 && p.systemPrompt() != nil {
+		return ErrNoMessages
+	}
+	for i, message := range p.Messages {
+		if err := validateRole(message.Role); err != nil {
+			return fmt.Errorf("invalid message %d: %w", i, err)
+		}
+		if message.Role == "assistant" {
+			if err := validateThinkingBlocks(message); err != nil {
+				return fmt.Errorf("invalid assistant message %d: %w", i, err)
+			}
+		}
+		for j, block := range message.Content {
+			if block.Type != "image" {
+				continue
+			}
+			if err := ValidateImage(block.Source); err != nil {
+				return fmt.Errorf("invalid image in message %d, content block %d: %w", i, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateRole checks that role is one of the roles allowed in Messages.
+func validateRole(role string) error {
+	switch Role(role) {
+	case RoleUser, RoleAssistant:
+		return nil
+	case "system":
+		return fmt.Errorf("%w %q: the system prompt is not a message, set it in MessageParams.System or SystemBlocks instead", ErrInvalidRole, role)
+	}
+	return fmt.Errorf("%w %q: must be %q or %q", ErrInvalidRole, role, RoleUser, RoleAssistant)
+}
+
+// validateThinkingBlocks checks the API's rules for thinking blocks in an assistant
+// turn: every thinking block must carry its signature, and a turn that calls tools
+// after thinking must start with a thinking or redacted_thinking block.
+func validateThinkingBlocks(message MessageParam) error {
+	hasThinking, hasToolUse := false, false
+	for j, block := range message.Content {
+		switch block.Type {
+		case "thinking":
+			if block.Signature == "" {
+				return fmt.Errorf("thinking block %d is missing its signature", j)
+			}
+			hasThinking = true
+		case "redacted_thinking":
+			hasThinking = true
+		case "tool_use":
+			hasToolUse = true
+		}
+	}
+	if hasThinking && hasToolUse {
+		if first := message.Content[0].Type; first != "thinking" && first != "redacted_thinking" {
+			return fmt.Errorf("an assistant turn with thinking and tool use must start with a thinking block, got %q", first)
+		}
+	}
+	return nil
+}
+
+// ToolResultMismatchError is returned when the tool_result blocks of a user turn do
+// not answer exactly the tool_use blocks of the preceding assistant turn.
+type ToolResultMismatchError struct {
+	// Missing lists the IDs of tool calls without a tool_result.
+	Missing []string
+	// Extra lists the IDs of tool_result blocks that answer no tool call, or answer
+	// one more than once.
+	Extra []string
+}
+
+// Error implements the error interface.
+func (e *ToolResultMismatchError) Error() string {
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("missing tool results for %s", strings.Join(e.Missing, ", ")))
+	}
+	if len(e.Extra) > 0 {
+		parts = append(parts, fmt.Sprintf("unexpected tool results for %s", strings.Join(e.Extra, ", ")))
+	}
+	return "tool results do not match tool calls: " + strings.Join(parts, "; ")
+}
+
+// ValidateToolResults checks that next, the turn following the assistant message,
+// contains exactly one tool_result for every tool_use of the assistant message, as
+// the API requires. It returns a *ToolResultMismatchError listing the missing and
+// extra tool use IDs otherwise.
+func ValidateToolResults(assistant *Message, next MessageParam) error {
+	return validateToolResults(assistant.Content, next)
+}
+
+func validateToolResults(assistantContent []ContentBlock, next MessageParam) error {
+	pending := make(map[string]bool)
+	var calls []string
+	for _, block := range assistantContent {
+		if block.Type == "tool_use" && block.ToolCall != nil {
+			pending[block.ToolCall.ID] = true
+			calls = append(calls, block.ToolCall.ID)
+		}
+	}
+
+	mismatch := &ToolResultMismatchError{}
+	for _, block := range next.Content {
+		if block.Type != "tool_result" {
+			continue
+		}
+		id := block.ToolUseID
+		if block.ToolOutput != nil && block.ToolOutput.ToolCallID != "" {
+			id = block.ToolOutput.ToolCallID
+		}
+		if !pending[id] {
+			mismatch.Extra = append(mismatch.Extra, id)
+			continue
+		}
+		delete(pending, id)
+	}
+	for _, id := range calls {
+		if pending[id] {
+			mismatch.Missing = append(mismatch.Missing, id)
+		}
+	}
+
+	if len(mismatch.Missing) == 0 && len(mismatch.Extra) == 0 {
+		return nil
+	}
+	return mismatch
+}
+
+// validateToolResultOrdering checks every assistant turn in the history that is
+// followed by another turn with validateToolResults.
+func validateToolResultOrdering(messages []MessageParam) error {
+	for i := 0; i+1 < len(messages); i++ {
+		if messages[i].Role != "assistant" {
+			continue
+		}
+		if err := validateToolResults(messages[i].Content, messages[i+1]); err != nil {
+			return fmt.Errorf("invalid tool results in message %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMessageParamsValidate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		params   *MessageParams
+		expected error
+	}{
+		{
+			name:     "System prompt without messages",
+			params:   &MessageParams{Model: string(ModelSonnet), System: "You are a helpful assistant."},
+			expected: ErrNoMessages,
+		},
+		{
+			name: "System prompt with messages",
+			params: &MessageParams{
+				Model:    string(ModelSonnet),
+				System:   "You are a helpful assistant.",
+				Messages: []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Hi"}}}},
+			},
+			expected: nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.params.Validate(); !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestValidateRoles(t *testing.T) {
+	testCases := []struct {
+		name        string
+		role        string
+		expectedErr string
+	}{
+		{name: "User", role: string(RoleUser)},
+		{name: "Assistant", role: string(RoleAssistant)},
+		{name: "System", role: "system", expectedErr: "set it in MessageParams.System"},
+		{name: "Unknown", role: "tool", expectedErr: `must be "user" or "assistant"`},
+		{name: "Empty", role: "", expectedErr: `invalid message role ""`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			params := &MessageParams{
+				Model: string(ModelSonnet),
+				Messages: []MessageParam{
+					{Role: string(RoleUser), Content: []ContentBlock{TextContent("Hi")}},
+					{Role: tc.role, Content: []ContentBlock{TextContent("Hello")}},
+				},
+			}
+			err := params.Validate()
+			if tc.expectedErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidRole) || !strings.Contains(err.Error(), tc.expectedErr) || !strings.Contains(err.Error(), "message 1") {
+				t.Errorf("Expected ErrInvalidRole for message 1 containing %q, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestCreateRejectsSystemOnlyRequest(t *testing.T) {
+	requests := 0
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		requests++
+		return testResponse{}
+	})
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	_, err := client.Messages().Create(context.Background(), &MessageParams{
+		Model:  string(ModelSonnet),
+		System: "You are a helpful assistant.",
+	})
+	if !errors.Is(err, ErrNoMessages) {
+		t.Errorf("Expected ErrNoMessages, got %v", err)
+	}
+	if requests != 0 {
+		t.Errorf("Expected no request to reach the server, got %d", requests)
+	}
+}
+
+func TestValidateSystemAndSystemBlocks(t *testing.T) {
+	params := &MessageParams{
+		System:       "Be terse.",
+		SystemBlocks: []SystemBlock{{Type: "text", Text: "Be terse."}},
+		Messages:     []MessageParam{{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Hi"}}}},
+	}
+	if err := params.Validate(); err == nil {
+		t.Error("Expected an error when both System and SystemBlocks are set")
+	}
+
+	params = &MessageParams{SystemBlocks: []SystemBlock{{Type: "text", Text: "Be terse."}}}
+	if err := params.Validate(); !errors.Is(err, ErrNoMessages) {
+		t.Errorf("Expected ErrNoMessages for system blocks without messages, got %v", err)
+	}
+}
+
+func TestValidateToolResults(t *testing.T) {
+	assistant := &Message{Role: "assistant", Content: []ContentBlock{
+		{Type: "text", Text: "Let me check."},
+		{Type: "tool_use", ToolCall: &ToolCall{ID: "toolu_1", Name: "a"}},
+		{Type: "tool_use", ToolCall: &ToolCall{ID: "toolu_2", Name: "b"}},
+	}}
+	result := func(id string) ContentBlock {
+		return ContentBlock{Type: "tool_result", ToolOutput: &ToolOutput{ToolCallID: id, Output: "ok"}}
+	}
+
+	testCases := []struct {
+		name            string
+		next            MessageParam
+		expectedMissing []string
+		expectedExtra   []string
+	}{
+		{name: "All answered", next: MessageParam{Role: "user", Content: []ContentBlock{result("toolu_2"), result("toolu_1")}}},
+		{name: "Missing result", next: MessageParam{Role: "user", Content: []ContentBlock{result("toolu_1")}}, expectedMissing: []string{"toolu_2"}},
+		{
+			name:            "Extra and duplicate results",
+			next:            MessageParam{Role: "user", Content: []ContentBlock{result("toolu_1"), result("toolu_1"), result("toolu_9")}},
+			expectedMissing: []string{"toolu_2"},
+			expectedExtra:   []string{"toolu_1", "toolu_9"},
+		},
+		{name: "No results", next: MessageParam{Role: "user", Content: []ContentBlock{{Type: "text", Text: "hi"}}}, expectedMissing: []string{"toolu_1", "toolu_2"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateToolResults(assistant, tc.next)
+			if tc.expectedMissing == nil && tc.expectedExtra == nil {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			var mismatch *ToolResultMismatchError
+			if !errors.As(err, &mismatch) {
+				t.Fatalf("Expected a *ToolResultMismatchError, got %v", err)
+			}
+			if !reflect.DeepEqual(mismatch.Missing, tc.expectedMissing) || !reflect.DeepEqual(mismatch.Extra, tc.expectedExtra) {
+				t.Errorf("Expected missing %v and extra %v, got missing %v and extra %v", tc.expectedMissing, tc.expectedExtra, mismatch.Missing, mismatch.Extra)
+			}
+		})
+	}
+}
+
+func TestWithStrictToolResultOrdering(t *testing.T) {
+	server := newTestServer(t, respondWith(testResponse{body: `{"id":"msg_123","role":"assistant","content":[]}`}))
+	defer server.Close()
+
+	params := &MessageParams{
+		Model: string(ModelSonnet),
+		Messages: []MessageParam{
+			{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Price?"}}},
+			mustAssistantToolUse(t, "toolu_1", "get_stock_price", map[string]string{"ticker": "^GSPC"}),
+			{Role: "user", Content: []ContentBlock{{Type: "text", Text: "Never mind."}}},
+		},
+	}
+
+	lenient, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	if _, err := lenient.Messages().Create(context.Background(), params); err != nil {
+		t.Errorf("Expected the lenient client to send the request, got %v", err)
+	}
+
+	strict, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL), WithStrictToolResultOrdering())
+	_, err := strict.Messages().Create(context.Background(), params)
+	var mismatch *ToolResultMismatchError
+	if !errors.As(err, &mismatch) || !reflect.DeepEqual(mismatch.Missing, []string{"toolu_1"}) {
+		t.Errorf("Expected a missing result for toolu_1, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	// WebSearchToolType is the versioned type of the server-side web search tool.
+	WebSearchToolType = "web_search_20250305"
+	// WebSearchToolName is the name the model uses to call the web search tool.
+	WebSearchToolName = "web_search"
+)
+
+// WebSearchTool configures Anthropic's server-side web search tool.
+// The search runs on Anthropic's side; results are returned in
+// web_search_tool_result content blocks.
+type WebSearchTool struct {
+	Type           string   `json:"type"`
+	Name           string   `json:"name"`
+	MaxUses        int      `json:"max_uses,omitempty"`
+	AllowedDomains []string `json:"allowed_domains,omitempty"`
+	BlockedDomains []string `json:"blocked_domains,omitempty"`
+}
+
+// NewWebSearchTool returns a Tool enabling server-side web search, limited to
+// maxUses searches per request (0 means no limit).
+func NewWebSearchTool(maxUses int) Tool {
+	return Tool{
+		Name: WebSearchToolName,
+		ServerTool: &WebSearchTool{
+			Type:    WebSearchToolType,
+			Name:    WebSearchToolName,
+			MaxUses: maxUses,
+		},
+	}
+}
+
+// WebSearchResult is a single result of a server-side web search.
+type WebSearchResult struct {
+	Type             string `json:"type"`
+	URL              string `json:"url"`
+	Title            string `json:"title"`
+	EncryptedContent string `json:"encrypted_content"`
+	PageAge          string `json:"page_age,omitempty"`
+}
+
+// webSearchToolResultError is the content of a failed web search.
+type webSearchToolResultError struct {
+	Type      string `json:"type"`
+	ErrorCode string `json:"error_code"`
+}
+
+// WebSearchResults decodes the results of a web_search_tool_result block.
+// If the search failed, the returned error carries the API's error code.
+func (b ContentBlock) WebSearchResults() ([]WebSearchResult, error) {
+	if b.Type != "web_search_tool_result" {
+		return nil, fmt.Errorf("content block of type %q is not a web search result", b.Type)
+	}
+	var results []WebSearchResult
+	if err := json.Unmarshal(b.Content, &results); err == nil {
+		return results, nil
+	}
+	var searchErr webSearchToolResultError
+	if err := json.Unmarshal(b.Content, &searchErr); err != nil {
+		return nil, fmt.Errorf("failed to decode web search result content: %w", err)
+	}
+	return nil, fmt.Errorf("web search failed: %s", searchErr.ErrorCode)
+}
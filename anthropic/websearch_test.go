@@ -0,0 +1,105 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestWebSearchToolMarshalJSON(t *testing.T) {
+	tools := []Tool{
+		{Name: "get_weather", Description: "Get the weather", InputSchema: InputSchema{Type: "object"}},
+		NewWebSearchTool(3),
+	}
+	jsonData, err := json.Marshal(tools)
+	if err != nil {
+		t.Fatalf("Failed to marshal tools: %v", err)
+	}
+	expected := `[{"name":"get_weather","description":"Get the weather","input_schema":{"type":"object"}},{"type":"web_search_20250305","name":"web_search","max_uses":3}]`
+	if string(jsonData) != expected {
+		t.Errorf("Expected JSON %s, got %s", expected, string(jsonData))
+	}
+}
+
+func TestMessagesService_CreateWithWebSearch(t *testing.T) {
+	server := newTestServer(t, func(r *http.Request) testResponse {
+		if !strings.Contains(r.Header.Get("anthropic-beta"), BetaWebSearch) {
+			t.Errorf("Expected beta header to contain %s, got %q", BetaWebSearch, r.Header.Get("anthropic-beta"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"type":"web_search_20250305"`) {
+			t.Errorf("Expected web search tool in request body, got %s", string(body))
+		}
+
+		return testResponse{body: `{
+			"id": "msg_123",
+			"type": "message",
+			"role": "assistant",
+			"content": [
+				{"type": "server_tool_use", "id": "srvtoolu_1", "name": "web_search", "input": {"query": "go 1.23 release date"}},
+				{"type": "web_search_tool_result", "tool_use_id": "srvtoolu_1", "content": [
+					{"type": "web_search_result", "url": "https://go.dev/blog/go1.23", "title": "Go 1.23 is released", "encrypted_content": "abc", "page_age": "August 13, 2024"}
+				]},
+				{"type": "text", "text": "Go 1.23 was released in August 2024."}
+			],
+			"stop_reason": "end_turn"
+		}`}
+	})
+	defer server.Close()
+
+	client, _ := NewClient(WithAPIKey("test-key"), WithBaseURL(server.URL))
+	message, err := client.Messages().Create(context.Background(), &MessageParams{
+		Model: string(ModelSonnet),
+		Messages: []MessageParam{
+			{Role: "user", Content: []ContentBlock{{Type: "text", Text: "When was Go 1.23 released?"}}},
+		},
+		Tools: []Tool{NewWebSearchTool(1)},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create message: %v", err)
+	}
+	if len(message.Content) != 3 {
+		t.Fatalf("Expected 3 content blocks, got %d", len(message.Content))
+	}
+
+	toolUse := message.Content[0]
+	if toolUse.ToolCall == nil || toolUse.ToolCall.ID != "srvtoolu_1" || toolUse.ToolCall.Name != WebSearchToolName {
+		t.Errorf("Expected server tool use to be mapped onto ToolCall, got %+v", toolUse)
+	}
+
+	results, err := message.Content[1].WebSearchResults()
+	if err != nil {
+		t.Fatalf("Failed to decode web search results: %v", err)
+	}
+	if len(results) != 1 || results[0].URL != "https://go.dev/blog/go1.23" || results[0].Title != "Go 1.23 is released" {
+		t.Errorf("Unexpected web search results: %+v", results)
+	}
+	if message.Content[1].ToolUseID != "srvtoolu_1" {
+		t.Errorf("Expected tool_use_id srvtoolu_1, got %s", message.Content[1].ToolUseID)
+	}
+}
+
+func TestWebSearchResultsError(t *testing.T) {
+	event := map[string]interface{}{
+		"index": float64(1),
+		"content_block": map[string]interface{}{
+			"type":        "web_search_tool_result",
+			"tool_use_id": "srvtoolu_1",
+			"content": map[string]interface{}{
+				"type":       "web_search_tool_result_error",
+				"error_code": "max_uses_exceeded",
+			},
+		},
+	}
+	response, err := handleContentBlockStartEvent(event, Message{})
+	if err != nil {
+		t.Fatalf("handleContentBlockStartEvent returned an error: %v", err)
+	}
+	_, err = response.Content[0].WebSearchResults()
+	if err == nil || !strings.Contains(err.Error(), "max_uses_exceeded") {
+		t.Errorf("Expected max_uses_exceeded error, got %v", err)
+	}
+}